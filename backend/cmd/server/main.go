@@ -4,8 +4,14 @@ import (
 	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/handlers"
+	"admin-dashboard/internal/mailer"
 	"admin-dashboard/internal/middlewares"
+	"admin-dashboard/internal/oidc"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
+	"admin-dashboard/internal/webauthn"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +22,16 @@ func main() {
 		log.Fatal("Failed to load config:", err)
 	}
 
+	// Load JWT signing keys
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
+	// Select password hashing parameters
+	if err := utils.InitPasswordHasher(); err != nil {
+		log.Fatal("Failed to initialize password hashing:", err)
+	}
+
 	// Set Gin mode
 	gin.SetMode(config.AppConfig.GinMode)
 
@@ -34,6 +50,34 @@ func main() {
 		log.Fatal("Failed to seed database:", err)
 	}
 
+	// Initialize outgoing mail
+	if err := mailer.Init(); err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
+	// Initialize captcha challenges
+	if err := handlers.InitCaptcha(); err != nil {
+		log.Fatal("Failed to initialize captcha:", err)
+	}
+
+	// Check the self-registration mode
+	if err := services.ValidateRegistrationMode(config.AppConfig.RegistrationMode); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	// Initialize passkeys
+	if err := webauthn.Init(); err != nil {
+		log.Fatal("Failed to initialize passkeys:", err)
+	}
+
+	// Initialize single sign-on; the rest of the app works without it
+	if err := oidc.Init(); err != nil {
+		log.Printf("WARNING: OIDC login disabled: %v", err)
+	}
+
+	// Prune expired entries from the token denylist
+	go services.PruneRevokedTokens(database.DB, time.Hour)
+
 	// Initialize router
 	r := gin.Default()
 
@@ -47,16 +91,35 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	// API routes
 	api := r.Group("/api")
 	{
 		// Auth routes (no auth required)
 		auth := api.Group("/auth")
 		{
-			auth.POST("/login", handlers.Login)
-			auth.POST("/register", handlers.Register)
-			auth.GET("/captcha", handlers.GetCaptcha)
+			auth.POST("/login", middlewares.RateLimitMiddleware("login", time.Minute, 5), handlers.Login)
+			auth.POST("/register", middlewares.RateLimitMiddleware("register", time.Hour, 5), handlers.Register)
+			auth.GET("/registration", handlers.GetRegistrationMode)
+			auth.GET("/captcha", middlewares.RateLimitMiddleware("captcha", time.Minute, 10), handlers.GetCaptcha)
+			auth.GET("/captcha/:id/image", handlers.GetCaptchaImage)
 			auth.POST("/verify-captcha", handlers.VerifyCaptcha)
+			auth.POST("/refresh", handlers.RefreshToken)
+			auth.POST("/2fa/setup", handlers.SetupTOTPForLogin)
+			auth.POST("/2fa/verify", middlewares.RateLimitMiddleware("2fa-verify", time.Minute, 10), handlers.VerifyTOTPForLogin)
+			auth.POST("/expired-password", middlewares.RateLimitMiddleware("expired-password", time.Minute, 5), handlers.ChangeExpiredPassword)
+			auth.POST("/forgot-password", middlewares.RateLimitMiddleware("forgot-password", time.Hour, 5), handlers.ForgotPassword)
+			auth.POST("/reset-password", middlewares.RateLimitMiddleware("reset-password", time.Minute, 5), handlers.ResetPassword)
+			auth.POST("/verify-email", middlewares.RateLimitMiddleware("verify-email", time.Minute, 10), handlers.VerifyEmail)
+			auth.POST("/magic-link", middlewares.RateLimitMiddleware("magic-link", time.Hour, 5), handlers.RequestMagicLink)
+			auth.POST("/magic-link/verify", middlewares.RateLimitMiddleware("magic-link-verify", time.Minute, 10), handlers.VerifyMagicLink)
+			auth.POST("/passkey/begin", middlewares.RateLimitMiddleware("passkey", time.Minute, 20), handlers.BeginPasskeyLogin)
+			auth.POST("/passkey/finish", middlewares.RateLimitMiddleware("passkey", time.Minute, 20), handlers.FinishPasskeyLogin)
+			auth.GET("/oidc/login", handlers.OIDCLogin)
+			auth.GET("/oidc/callback", handlers.OIDCCallback)
+			auth.POST("/oidc/complete", middlewares.RateLimitMiddleware("oidc-complete", time.Minute, 5), handlers.OIDCComplete)
 		}
 
 		// Protected routes
@@ -65,14 +128,56 @@ func main() {
 		{
 			// Current user
 			protected.GET("/me", handlers.GetCurrentUser)
+			protected.PATCH("/me", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handlers.UpdateProfile)
+			protected.DELETE("/me", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handlers.DeactivateOwnAccount)
+			protected.POST("/me/password", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handlers.ChangeOwnPassword)
+			protected.POST("/me/email/verification", middlewares.RateLimitMiddleware("verification-email", time.Hour, 5), handlers.ResendVerificationEmail)
+			protected.GET("/me/sessions", handlers.GetMySessions)
+			protected.DELETE("/me/sessions/:sessionId", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handlers.RevokeMySession)
+			protected.POST("/auth/logout", handlers.Logout)
+
+			// Two-factor authentication
+			twoFactor := protected.Group("/me/2fa")
+			twoFactor.Use(middlewares.RejectAPIKeys(), middlewares.RejectImpersonation())
+			{
+				twoFactor.POST("/setup", handlers.SetupTOTP)
+				twoFactor.POST("/enable", handlers.EnableTOTP)
+				twoFactor.POST("/disable", handlers.DisableTOTP)
+				twoFactor.POST("/recovery-codes", handlers.RegenerateRecoveryCodes)
+			}
+
+			// API keys
+			apiKeys := protected.Group("/me/api-keys")
+			apiKeys.Use(middlewares.RejectAPIKeys(), middlewares.RejectImpersonation())
+			{
+				apiKeys.GET("", handlers.GetAPIKeys)
+				apiKeys.POST("", handlers.CreateAPIKey)
+				apiKeys.DELETE("/:id", handlers.RevokeAPIKey)
+			}
+
+			// Passkeys
+			passkeys := protected.Group("/me/passkeys")
+			passkeys.Use(middlewares.RejectAPIKeys(), middlewares.RejectImpersonation())
+			{
+				passkeys.GET("", handlers.GetPasskeys)
+				passkeys.POST("/register/begin", handlers.BeginPasskeyRegistration)
+				passkeys.POST("/register/finish", handlers.FinishPasskeyRegistration)
+				passkeys.PATCH("/:id", handlers.RenamePasskey)
+				passkeys.DELETE("/:id", handlers.DeletePasskey)
+			}
 
-			// Roles and Permissions
 			// Roles and Permissions
 			roles := protected.Group("/roles")
 			{
 				roles.GET("", handlers.GetRoles)
+				roles.POST("", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.CreateRole)
+				roles.PUT("/:id", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.UpdateRole)
+				roles.DELETE("/:id", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.DeleteRole)
 				roles.GET("/:id/permissions", handlers.GetRolePermissions)
-				roles.POST("/:id/permissions", middlewares.RequirePermission("ROLE_MANAGE"), handlers.AssignRolePermissions)
+				roles.POST("/:id/permissions", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.AssignRolePermissions)
+				roles.PUT("/:id/parent", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.SetRoleParent)
+				roles.PUT("/:id/require-2fa", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.SetRoleRequire2FA)
+				roles.PUT("/:id/magic-link", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.SetRoleMagicLink)
 			}
 
 			permissions := protected.Group("/permissions")
@@ -80,6 +185,22 @@ func main() {
 				permissions.GET("", handlers.GetPermissions)
 			}
 
+			// Password policy is readable by everyone so forms can show the rules
+			passwordPolicy := protected.Group("/password-policy")
+			{
+				passwordPolicy.GET("", handlers.GetPasswordPolicy)
+				passwordPolicy.PUT("", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.UpdatePasswordPolicy)
+			}
+
+			// Policies that limit user and role changes; anyone can dry-run
+			// their own requests, and EvaluatePolicy checks the permissions
+			// needed to look at other users
+			policies := protected.Group("/policy")
+			{
+				policies.GET("/rules", handlers.GetPolicyRules)
+				policies.POST("/evaluate", handlers.EvaluatePolicy)
+			}
+
 			// Users
 			users := protected.Group("/users")
 			users.Use(middlewares.RequirePermission("USER_READ"))
@@ -87,10 +208,31 @@ func main() {
 				users.GET("", handlers.GetUsers)
 				users.GET("/:id", handlers.GetUser)
 				users.POST("", middlewares.RequirePermission("USER_CREATE"), handlers.CreateUser)
-				users.PUT("/:id", middlewares.RequirePermission("USER_UPDATE"), handlers.UpdateUser)
+				users.PUT("/:id", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.UpdateUser)
 				users.DELETE("/:id", middlewares.RequirePermission("USER_DELETE"), handlers.DeleteUser)
+				users.POST("/:id/revoke-tokens", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.RevokeUserTokens)
+				users.POST("/:id/reset-2fa", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.ResetUserTOTP)
+				users.POST("/:id/unlock", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.UnlockUser)
+				users.GET("/:id/sessions", middlewares.RequirePermission("USER_UPDATE"), handlers.GetUserSessions)
+				users.GET("/:id/permissions", handlers.GetUserAccess)
+				users.PUT("/:id/roles", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.SetUserRoles)
+				users.PUT("/:id/permission-overrides", middlewares.RejectImpersonation(), middlewares.RequirePermission("ROLE_MANAGE"), handlers.SetUserPermissionOverrides)
+				users.DELETE("/:id/sessions/:handle", middlewares.RejectImpersonation(), middlewares.RequirePermission("USER_UPDATE"), handlers.RevokeUserSession)
+				users.POST("/:id/impersonate", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), middlewares.RequirePermission("IMPERSONATE"), handlers.ImpersonateUser)
 			}
 
+			// Invitations
+			invitations := protected.Group("/invitations")
+			invitations.Use(middlewares.RequirePermission("USER_CREATE"))
+			{
+				invitations.GET("", handlers.GetInvitations)
+				invitations.POST("", handlers.CreateInvitation)
+				invitations.DELETE("/:id", handlers.RevokeInvitation)
+			}
+
+			// Audit log
+			protected.GET("/audit-logs", middlewares.RequirePermission("ROLE_MANAGE"), handlers.GetAuditLogs)
+
 			// Analytics
 			analytics := protected.Group("/analytics")
 			analytics.Use(middlewares.RequirePermission("ANALYTICS_VIEW"))
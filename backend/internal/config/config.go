@@ -9,17 +9,57 @@ import (
 )
 
 type Config struct {
-	Port            string
-	GinMode         string
-	DBHost          string
-	DBPort          string
-	DBUser          string
-	DBPassword      string
-	DBName          string
-	DBSSLMode       string
-	JWTSecret       string
-	JWTExpirationHours int
-	CORSOrigin      string
+	Port                  string
+	GinMode               string
+	DBHost                string
+	DBPort                string
+	DBUser                string
+	DBPassword            string
+	DBName                string
+	DBSSLMode             string
+	JWTSecret             string
+	JWTSigningAlg         string
+	JWTKeysDir            string
+	JWTActiveKid          string
+	JWTAccessTTLMinutes   int
+	JWTRefreshTTLHours    int
+	ImpersonationTTL      int
+	TOTPIssuer            string
+	LoginMaxFailures      int
+	LoginLockoutMinutes   int
+	PasswordResetTTL      int
+	EmailVerificationTTL  int
+	MagicLinkTTL          int
+	PasswordBlocklistFile string
+	PasswordHashAlgorithm string
+	Argon2MemoryKB        int
+	Argon2Time            int
+	Argon2Threads         int
+	BcryptCost            int
+	RegistrationMode      string
+	CaptchaProvider       string
+	CaptchaStore          string
+	CORSOrigin            string
+	FrontendURL           string
+	MailDriver            string
+	MailFrom              string
+	MailOutboxDir         string
+	SMTPHost              string
+	SMTPPort              string
+	SMTPUsername          string
+	SMTPPassword          string
+	OIDCEnabled           bool
+	OIDCIssuer            string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            string
+	OIDCGroupsClaim       string
+	OIDCRoleMapping       string
+	OIDCDefaultRole       string
+	WebAuthnRPID          string
+	WebAuthnRPName        string
+	WebAuthnOrigins       string
 }
 
 var AppConfig *Config
@@ -29,20 +69,60 @@ func LoadConfig() error {
 	_ = godotenv.Load()
 
 	AppConfig = &Config{
-		Port:            getEnv("PORT", "4010"),
-		GinMode:         getEnv("GIN_MODE", "debug"),
-		DBHost:          getEnv("DB_HOST", "localhost"),
-		DBPort:          getEnv("DB_PORT", "5432"),
-		DBUser:          getEnv("DB_USER", "postgres"),
-		DBPassword:      getEnv("DB_PASSWORD", "postgres"),
-		DBName:          getEnv("DB_NAME", "admin_dashboard"),
-		DBSSLMode:       getEnv("DB_SSLMODE", "disable"),
-		JWTSecret:       getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
-		JWTExpirationHours: getEnvAsInt("JWT_EXPIRATION_HOURS", 24),
-		CORSOrigin:      getEnv("CORS_ORIGIN", "http://localhost:4011"),
+		Port:                  getEnv("PORT", "4010"),
+		GinMode:               getEnv("GIN_MODE", "debug"),
+		DBHost:                getEnv("DB_HOST", "localhost"),
+		DBPort:                getEnv("DB_PORT", "5432"),
+		DBUser:                getEnv("DB_USER", "postgres"),
+		DBPassword:            getEnv("DB_PASSWORD", "postgres"),
+		DBName:                getEnv("DB_NAME", "admin_dashboard"),
+		DBSSLMode:             getEnv("DB_SSLMODE", "disable"),
+		JWTSecret:             getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
+		JWTSigningAlg:         getEnv("JWT_SIGNING_ALG", "RS256"),
+		JWTKeysDir:            getEnv("JWT_KEYS_DIR", "./keys"),
+		JWTActiveKid:          getEnv("JWT_ACTIVE_KID", ""),
+		JWTAccessTTLMinutes:   getEnvAsInt("JWT_ACCESS_TTL_MINUTES", 15),
+		JWTRefreshTTLHours:    getEnvAsInt("JWT_REFRESH_TTL_HOURS", 168),
+		ImpersonationTTL:      getEnvAsInt("IMPERSONATION_TTL_MINUTES", 10),
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "Admin Dashboard"),
+		LoginMaxFailures:      getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginLockoutMinutes:   getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+		PasswordResetTTL:      getEnvAsInt("PASSWORD_RESET_TTL_MINUTES", 30),
+		EmailVerificationTTL:  getEnvAsInt("EMAIL_VERIFICATION_TTL_HOURS", 48),
+		MagicLinkTTL:          getEnvAsInt("MAGIC_LINK_TTL_MINUTES", 10),
+		PasswordBlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2MemoryKB:        getEnvAsInt("ARGON2_MEMORY_KB", 65536),
+		Argon2Time:            getEnvAsInt("ARGON2_TIME", 3),
+		Argon2Threads:         getEnvAsInt("ARGON2_THREADS", 2),
+		BcryptCost:            getEnvAsInt("BCRYPT_COST", 10),
+		RegistrationMode:      getEnv("REGISTRATION_MODE", "invite"),
+		CaptchaProvider:       getEnv("CAPTCHA_PROVIDER", "math"),
+		CaptchaStore:          getEnv("CAPTCHA_STORE", "memory"),
+		CORSOrigin:            getEnv("CORS_ORIGIN", "http://localhost:4011"),
+		FrontendURL:           getEnv("FRONTEND_URL", "http://localhost:4011"),
+		MailDriver:            getEnv("MAIL_DRIVER", "file"),
+		MailFrom:              getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailOutboxDir:         getEnv("MAIL_OUTBOX_DIR", "./tmp/outbox"),
+		SMTPHost:              getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:              getEnv("SMTP_PORT", "587"),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		OIDCEnabled:           getEnvAsBool("OIDC_ENABLED", false),
+		OIDCIssuer:            getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:          getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       getEnv("OIDC_REDIRECT_URL", "http://localhost:4010/api/auth/oidc/callback"),
+		OIDCScopes:            getEnv("OIDC_SCOPES", "openid profile email"),
+		OIDCGroupsClaim:       getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:       getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCDefaultRole:       getEnv("OIDC_DEFAULT_ROLE", ""),
+		WebAuthnRPID:          getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:        getEnv("WEBAUTHN_RP_NAME", "Admin Dashboard"),
+		WebAuthnOrigins:       getEnv("WEBAUTHN_ORIGINS", ""),
 	}
 
-	if AppConfig.JWTSecret == "your-super-secret-jwt-key-change-in-production" {
+	if AppConfig.JWTSigningAlg == "HS256" && AppConfig.JWTSecret == "your-super-secret-jwt-key-change-in-production" {
 		fmt.Println("WARNING: Using default JWT secret. Change this in production!")
 	}
 
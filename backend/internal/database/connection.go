@@ -3,6 +3,7 @@ package database
 import (
 	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
 	"admin-dashboard/internal/utils"
 	"fmt"
 	"log"
@@ -36,17 +37,72 @@ func Migrate() error {
 		return fmt.Errorf("database connection not initialized")
 	}
 
+	// Checked before AutoMigrate adds the column
+	rolesRanked := DB.Migrator().HasColumn(&models.Role{}, "Rank")
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.Role{},
 		&models.Permission{},
 		&models.RolePermission{},
 		&models.ChatMessage{},
+		&models.Session{},
+		&models.RetiredRefreshToken{},
+		&models.RevokedToken{},
+		&models.RecoveryCode{},
+		&models.LoginAttempt{},
+		&models.PasswordResetToken{},
+		&models.APIKey{},
+		&models.ExternalIdentity{},
+		&models.PasswordPolicy{},
+		&models.PasswordHistory{},
+		&models.CaptchaChallenge{},
+		&models.WebAuthnCredential{},
+		&models.AuditLog{},
+		&models.Invitation{},
+		&models.EmailVerificationToken{},
+		&models.MagicLinkToken{},
+		&models.UserPermissionOverride{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Emails are compared case-insensitively; older rows may predate normalization
+	if err := DB.Exec("UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email))").Error; err != nil {
+		return fmt.Errorf("failed to normalize emails: %w", err)
+	}
+	if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))").Error; err != nil {
+		return fmt.Errorf("failed to index emails: %w", err)
+	}
+
+	// users.role_id is NOT NULL, so the old ON DELETE SET NULL could only ever
+	// fail; users are now moved to another role before theirs is deleted
+	var roleDeleteRule string
+	DB.Raw("SELECT delete_rule FROM information_schema.referential_constraints WHERE constraint_name = ?", "fk_users_role").Scan(&roleDeleteRule)
+	if roleDeleteRule == "SET NULL" {
+		if err := DB.Migrator().DropConstraint(&models.User{}, "Role"); err != nil {
+			return fmt.Errorf("failed to drop users role constraint: %w", err)
+		}
+		if err := DB.Migrator().CreateConstraint(&models.User{}, "Role"); err != nil {
+			return fmt.Errorf("failed to create users role constraint: %w", err)
+		}
+	}
+
+	// Users used to hold exactly one role (users.role_id); give anyone without
+	// a role set that role
+	if err := DB.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT id, role_id FROM users
+		WHERE NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)`).Error; err != nil {
+		return fmt.Errorf("failed to migrate user roles: %w", err)
+	}
+
+	if !rolesRanked {
+		if err := models.RankBuiltInRoles(DB); err != nil {
+			return fmt.Errorf("failed to rank built-in roles: %w", err)
+		}
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -56,8 +112,12 @@ func Seed() error {
 		return fmt.Errorf("database connection not initialized")
 	}
 
-	if err := models.SeedRolesAndPermissions(DB); err != nil {
-		return err
+	// Roles first, so new permissions can be granted to them
+	if err := models.SeedRoles(DB); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+	if err := permissions.Reconcile(DB); err != nil {
+		return fmt.Errorf("failed to reconcile permissions: %w", err)
 	}
 
 	// Seed default admin user (admin/admin) if none exists
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"admin-dashboard/internal/permissions"
 	"admin-dashboard/internal/services"
 	"net/http"
 	"time"
@@ -8,6 +9,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+func init() {
+	permissions.Register(permissions.Definition{
+		Name:         "ANALYTICS_VIEW",
+		Description:  "View analytics and reports",
+		DefaultRoles: []string{"admin", "manager", "viewer"},
+	})
+}
+
 type AnalyticsResponse struct {
 	KPIs       services.KPIStats     `json:"kpis"`
 	LineChart  services.LineChartData `json:"line_chart"`
@@ -1,47 +1,99 @@
 package handlers
 
 import (
+	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
 	"admin-dashboard/internal/services"
 	"admin-dashboard/internal/utils"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 var (
-	captchaStore   *services.CaptchaStore
-	usernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	captchaStore *services.CaptchaStore
+	mfaStore     *services.MFAStore
+	// passwordChangeStore holds logins that passed every step but must
+	// replace an expired password before they get a session
+	passwordChangeStore *services.MFAStore
+	usernameRegexp      = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 )
 
 func init() {
-	captchaStore = services.NewCaptchaStore()
+	mfaStore = services.NewMFAStore()
+	passwordChangeStore = services.NewMFAStore()
+}
+
+// InitCaptcha sets up the captcha store with the provider chosen in
+// CAPTCHA_PROVIDER and the storage chosen in CAPTCHA_STORE. It needs the database connection.
+func InitCaptcha() error {
+	provider, err := utils.NewCaptchaProvider(config.AppConfig.CaptchaProvider)
+	if err != nil {
+		return err
+	}
+
+	var storage services.CaptchaStorage
+	switch config.AppConfig.CaptchaStore {
+	case "postgres":
+		storage = services.NewPostgresCaptchaStorage(database.DB)
+	case "memory", "":
+		storage = services.NewMemoryCaptchaStorage()
+	default:
+		return fmt.Errorf("unknown captcha store %q", config.AppConfig.CaptchaStore)
+	}
+
+	captchaStore = services.NewCaptchaStore(provider, storage)
+	log.Printf("Captcha provider: %s (%s store)", provider.Name(), config.AppConfig.CaptchaStore)
+	return nil
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"`
 	FullName string `json:"full_name"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	// InvitationCode is required in invite-only mode; in open mode it picks the role
+	InvitationCode string `json:"invitation_code"`
 }
 
 type RegisterResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresAt    time.Time   `json:"expires_at"`
+	User         models.User `json:"user"`
 }
 
 type LoginRequest struct {
+	// Username also accepts a verified email address
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
+type ChangeExpiredPasswordRequest struct {
+	PasswordChangeToken string `json:"password_change_token" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required"`
+}
+
 type LoginResponse struct {
 	Message         string `json:"message"`
 	RequiresCaptcha bool   `json:"requires_captcha"`
 	CaptchaID       string `json:"captcha_id,omitempty"`
 	CaptchaQuestion string `json:"captcha_question,omitempty"`
+	CaptchaType     string `json:"captcha_type,omitempty"`
+	// Username is the name to send back with the captcha answer, for logins
+	// that didn't start from a username
+	Username string `json:"username,omitempty"`
 }
 
 type VerifyCaptchaRequest struct {
@@ -51,8 +103,28 @@ type VerifyCaptchaRequest struct {
 }
 
 type VerifyCaptchaResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token         string      `json:"token"`
+	RefreshToken  string      `json:"refresh_token"`
+	ExpiresAt     time.Time   `json:"expires_at"`
+	User          models.User `json:"user"`
+	RecoveryCodes []string    `json:"recovery_codes,omitempty"`
+}
+
+type MFARequiredResponse struct {
+	Message           string `json:"message"`
+	RequiresTOTP      bool   `json:"requires_totp"`
+	RequiresTOTPSetup bool   `json:"requires_totp_setup"`
+	MFAToken          string `json:"mfa_token"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type RefreshResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 func Login(c *gin.Context) {
@@ -62,42 +134,123 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by username
+	attempt := models.LoginAttempt{
+		Username:  req.Username,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	// Find user by username or verified email
 	var user models.User
-	if err := database.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	if err := findLoginUser(req.Username).First(&user).Error; err != nil {
+		attempt.Reason = services.LoginReasonUnknownUser
+		services.RecordLoginAttempt(database.DB, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	attempt.UserID = &user.ID
 
 	// Check if user is active
 	if !user.IsActive {
+		attempt.Reason = services.LoginReasonInactive
+		services.RecordLoginAttempt(database.DB, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is inactive"})
 		return
 	}
 
+	// Enforce lockout and progressive delay before even looking at the password
+	if wait, locked := services.LoginRetryAfter(&user); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		if locked {
+			attempt.Reason = services.LoginReasonLocked
+			services.RecordLoginAttempt(database.DB, attempt)
+			c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked due to too many failed attempts", "error_code": "ACCOUNT_LOCKED"})
+			return
+		}
+		attempt.Reason = services.LoginReasonThrottled
+		services.RecordLoginAttempt(database.DB, attempt)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts. Please wait before trying again.", "error_code": "LOGIN_THROTTLED"})
+		return
+	}
+
 	// Verify password
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		if err := services.RecordFailedLogin(database.DB, &user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+			return
+		}
+		attempt.Reason = services.LoginReasonInvalidPassword
+		services.RecordLoginAttempt(database.DB, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate captcha challenge
-	captcha := captchaStore.Generate()
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := services.ResetFailedLogins(database.DB, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+			return
+		}
+	}
+	attempt.Success = true
+	services.RecordLoginAttempt(database.DB, attempt)
+
+	// Move old hashes to the current algorithm while the plaintext is at hand
+	if utils.PasswordNeedsRehash(user.PasswordHash) {
+		if err := services.UpgradePasswordHash(database.DB, &user, req.Password); err != nil {
+			log.Printf("Failed to upgrade password hash for user %s: %v", user.ID, err)
+		}
+	}
+
+	// Expired or admin-reset passwords must be replaced, but only once the
+	// captcha and second factor have passed too
+	mustChange, err := services.PasswordChangeRequired(database.DB, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return
+	}
+
+	// Generate captcha challenge bound to this credential check, under the
+	// name the client logged in with so it can send the same one back
+	captcha, err := captchaStore.GenerateFor(user.ID, req.Username, mustChange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate captcha"})
+		return
+	}
 
 	c.JSON(http.StatusOK, LoginResponse{
 		Message:         "Credentials verified. Please solve the captcha.",
 		RequiresCaptcha: true,
 		CaptchaID:       captcha.ID,
 		CaptchaQuestion: captcha.Question,
+		CaptchaType:     captcha.Type,
 	})
 }
 
+// GetCaptcha shows what the captcha step looks like. The challenge isn't
+// stored and can't be answered; image captchas carry their PNG inline.
 func GetCaptcha(c *gin.Context) {
-	captcha := captchaStore.Generate()
-	c.JSON(http.StatusOK, gin.H{
-		"id":       captcha.ID,
+	captcha := captchaStore.Preview()
+
+	response := gin.H{
 		"question": captcha.Question,
-	})
+		"type":     captcha.Type,
+	}
+	if len(captcha.Image) > 0 {
+		response["image"] = base64.StdEncoding.EncodeToString(captcha.Image)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCaptchaImage serves the picture of an image captcha. Fetching it doesn't use up the challenge.
+func GetCaptchaImage(c *gin.Context) {
+	image, ok := captchaStore.Image(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Captcha not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", image)
 }
 
 func VerifyCaptcha(c *gin.Context) {
@@ -119,20 +272,118 @@ func VerifyCaptcha(c *gin.Context) {
 		return
 	}
 
-	if !captchaStore.Verify(req.CaptchaID, req.Answer) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid captcha. Please try again."})
+	// The captcha must come from this user's own password check
+	challenge, err := captchaStore.Verify(req.CaptchaID, req.Username, req.Answer)
+	if err != nil {
+		if err == services.ErrCaptchaWrongAnswer {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid captcha. Please try again.", "error_code": "CAPTCHA_INVALID"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Captcha expired or invalid. Please log in again.", "error_code": "CAPTCHA_EXPIRED"})
+		return
+	}
+
+	// Verify user still exists and is active
+	var user models.User
+	if err := database.DB.Preload("Role").Where("id = ? AND is_active = ?", challenge.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+		return
+	}
+
+	continueLogin(c, &user, "Captcha verified.", challenge.PasswordChange)
+}
+
+// ChangeExpiredPassword finishes a login that passed every step but had to
+// replace its password first.
+func ChangeExpiredPassword(c *gin.Context) {
+	var req ChangeExpiredPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pending, ok := passwordChangeStore.Get(req.PasswordChangeToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired password change. Please log in again."})
 		return
 	}
 
-	// Verify user exists and is active
 	var user models.User
-	if err := database.DB.Preload("Role").Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error; err != nil {
+	if err := database.DB.Preload("Role").Where("id = ? AND is_active = ?", pending.UserID, true).First(&user).Error; err != nil {
+		passwordChangeStore.Consume(req.PasswordChangeToken)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.String(), user.Username, user.RoleID.String())
+	if err := services.ChangePassword(database.DB, &user, req.NewPassword); err != nil {
+		// A rejected password leaves the token usable for another try
+		respondPasswordError(c, err, "Failed to change password")
+		return
+	}
+
+	passwordChangeStore.Consume(req.PasswordChangeToken)
+	completeLogin(c, &user, nil, false)
+}
+
+// findLoginUser looks up a user by the name given at login. Anything with an @
+// is taken as an email address, which only works once it has been verified.
+func findLoginUser(login string) *gorm.DB {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		return database.DB.Where("email = ? AND email_verified = ?", normalizeEmail(login), true)
+	}
+	return database.DB.Where("username = ?", login)
+}
+
+// continueLogin sends users with 2FA (or whose role requires it) to the second
+// factor step, and completes the login for everyone else. verified describes
+// the step that just passed, for the response message. passwordChange is
+// passed on to completeLogin.
+func continueLogin(c *gin.Context, user *models.User, verified string, passwordChange bool) {
+	if user.TOTPEnabled || services.RoleRequires2FA(database.DB, user) {
+		mfaToken, err := mfaStore.Create(user.ID, !user.TOTPEnabled, passwordChange)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor verification"})
+			return
+		}
+
+		message := verified + " Please enter your two-factor code."
+		if !user.TOTPEnabled {
+			message = verified + " Your role requires two-factor authentication; please set it up."
+		}
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			Message:           message,
+			RequiresTOTP:      user.TOTPEnabled,
+			RequiresTOTPSetup: !user.TOTPEnabled,
+			MFAToken:          mfaToken,
+		})
+		return
+	}
+
+	completeLogin(c, user, nil, passwordChange)
+}
+
+// completeLogin starts a session for a user who passed every login step and writes the token response.
+// With passwordChange set, the user gets a token for ChangeExpiredPassword instead of a session.
+func completeLogin(c *gin.Context, user *models.User, recoveryCodes []string, passwordChange bool) {
+	if passwordChange {
+		token, err := passwordChangeStore.Create(user.ID, false, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                    "Your password has expired. Please choose a new one.",
+			"error_code":               "PASSWORD_CHANGE_REQUIRED",
+			"requires_password_change": true,
+			"password_change_token":    token,
+			"recovery_codes":           recoveryCodes,
+		})
+		return
+	}
+
+	// Start a session and issue access + refresh tokens
+	tokens, err := services.CreateSession(database.DB, user, sessionMeta(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -142,18 +393,66 @@ func VerifyCaptcha(c *gin.Context) {
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, VerifyCaptchaResponse{
-		Token: token,
-		User:  user,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresAt:     tokens.ExpiresAt,
+		User:          *user,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, _, err := services.RefreshSession(database.DB, req.RefreshToken, sessionMeta(c))
+	if err != nil {
+		switch err {
+		case services.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used. Please log in again.", "error_code": "REFRESH_TOKEN_REUSED"})
+		case services.ErrInvalidRefreshToken, services.ErrSessionInactive:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token", "error_code": "REFRESH_TOKEN_INVALID"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
 	})
 }
 
+// GetRegistrationMode tells the register page whether sign-up is open,
+// invite-only or disabled.
+func GetRegistrationMode(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"mode": services.RegistrationMode()})
+}
+
 func Register(c *gin.Context) {
+	mode := services.RegistrationMode()
+	if mode == services.RegistrationDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled", "error_code": "REGISTRATION_DISABLED"})
+		return
+	}
+
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": "VALIDATION"})
 		return
 	}
 
+	invitationCode := strings.TrimSpace(req.InvitationCode)
+	if mode == services.RegistrationInvite && invitationCode == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "An invitation code is required to register", "error_code": "INVITATION_REQUIRED"})
+		return
+	}
+
 	// Validate username format (letters, numbers, underscores, hyphens)
 	if !usernameRegexp.MatchString(req.Username) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username can only contain letters, numbers, underscores and hyphens", "error_code": "USERNAME_INVALID"})
@@ -167,16 +466,29 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Get default "viewer" role for self-registered users
-	var viewerRole models.Role
-	if err := database.DB.Where("name = ?", "viewer").First(&viewerRole).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Registration is not available", "error_code": "REGISTRATION_UNAVAILABLE"})
+	// Check if email already exists
+	email := normalizeEmail(req.Email)
+	if email != "" && emailTaken(email, uuid.Nil) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists", "error_code": "EMAIL_TAKEN"})
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	// Get default "viewer" role for self-registered users without an invitation
+	var viewerRole models.Role
+	if invitationCode == "" {
+		if err := database.DB.Where("name = ?", "viewer").First(&viewerRole).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Registration is not available", "error_code": "REGISTRATION_UNAVAILABLE"})
+			return
+		}
+	}
+
+	// Check the password policy and hash
+	hashedPassword, err := services.HashNewPassword(database.DB, req.Username, req.Password)
 	if err != nil {
+		if _, ok := services.IsPasswordPolicyError(err); ok {
+			respondPasswordError(c, err, "Failed to create account")
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account", "error_code": "CREATE_FAILED"})
 		return
 	}
@@ -187,33 +499,118 @@ func Register(c *gin.Context) {
 		fullName = req.Username
 	}
 
+	now := time.Now()
 	user := models.User{
-		ID:           uuid.New(),
-		FullName:     fullName,
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
-		RoleID:       viewerRole.ID,
-		IsActive:     true,
+		ID:                uuid.New(),
+		FullName:          fullName,
+		Username:          req.Username,
+		PasswordHash:      hashedPassword,
+		PasswordChangedAt: &now,
+		RoleID:            viewerRole.ID,
+		IsActive:          true,
+	}
+	if email != "" {
+		user.Email = &email
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if invitationCode != "" {
+			invitation, err := services.RedeemInvitation(tx, invitationCode)
+			if err != nil {
+				return err
+			}
+			user.RoleID = invitation.RoleID
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return services.RecordPasswordHistory(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
+		if err == services.ErrInvitationInvalid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invitation code is invalid, expired or already used", "error_code": "INVITATION_INVALID"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account", "error_code": "CREATE_FAILED"})
 		return
 	}
+	sendVerificationEmail(&user)
 
 	// Load role for response
 	database.DB.Preload("Role").First(&user, user.ID)
 	user.PasswordHash = ""
 
-	// Generate JWT token (auto-login after registration)
-	token, err := utils.GenerateJWT(user.ID.String(), user.Username, user.RoleID.String())
+	// Invitations can assign roles that require 2FA; those users set it up
+	// through the login's second factor step before getting a session
+	if services.RoleRequires2FA(database.DB, &user) {
+		mfaToken, err := mfaStore.Create(user.ID, true, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Account created but login failed", "error_code": "LOGIN_AFTER_FAILED"})
+			return
+		}
+		c.JSON(http.StatusCreated, MFARequiredResponse{
+			Message:           "Account created. Your role requires two-factor authentication; please set it up.",
+			RequiresTOTPSetup: true,
+			MFAToken:          mfaToken,
+		})
+		return
+	}
+
+	// Start a session (auto-login after registration)
+	tokens, err := services.CreateSession(database.DB, &user, sessionMeta(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Account created but login failed", "error_code": "LOGIN_AFTER_FAILED"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, RegisterResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user,
 	})
 }
+
+func Logout(c *gin.Context) {
+	claimsInterface, exists := c.Get("claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims := claimsInterface.(*utils.Claims)
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in token"})
+		return
+	}
+
+	// Deny the access token for the rest of its lifetime
+	if err := services.RevokeToken(database.DB, claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	// An impersonation token shares the admin's session, which stays signed in
+	if impersonator, ok := c.Get("impersonator"); ok {
+		services.RecordAudit(database.DB, models.AuditLog{
+			ActorID: impersonator.(*models.User).ID,
+			UserID:  &userID,
+			Action:  services.AuditImpersonationStop,
+			IP:      c.ClientIP(),
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended"})
+		return
+	}
+
+	// End the session so its refresh token can't mint new access tokens
+	if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+		if err := services.RevokeSession(database.DB, sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		hub.CloseSession(sessionID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
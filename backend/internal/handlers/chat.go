@@ -1,14 +1,15 @@
 package handlers
 
 import (
-	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
 	ws "admin-dashboard/internal/websocket"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -25,6 +26,12 @@ var (
 )
 
 func init() {
+	permissions.Register(permissions.Definition{
+		Name:         "CHAT_SEND",
+		Description:  "Send chat messages",
+		DefaultRoles: []string{"admin", "manager", "viewer"},
+	})
+
 	hub = ws.NewHub()
 	go hub.Run()
 }
@@ -46,20 +53,25 @@ func HandleWebSocket(c *gin.Context) {
 	}
 
 	// Parse and validate token
-	type Claims struct {
-		UserID   string `json:"user_id"`
-		Username string `json:"username"`
-		RoleID   string `json:"role_id"`
-		jwt.RegisteredClaims
+	claims, err := utils.ParseJWT(tokenStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
 	}
 
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.AppConfig.JWTSecret), nil
-	})
+	if claims.ID == "" || services.IsTokenRevoked(database.DB, claims.ID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		return
+	}
 
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	// Messages would go out under the impersonated user's name
+	if claims.Actor != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chat is not available while impersonating"})
+		return
+	}
+
+	if claims.SessionID == "" || !services.IsSessionActive(database.DB, claims.SessionID, claims.UserID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
 		return
 	}
 
@@ -68,6 +80,11 @@ func HandleWebSocket(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in token"})
 		return
 	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID in token"})
+		return
+	}
 
 	// Get user details
 	var user models.User
@@ -85,11 +102,12 @@ func HandleWebSocket(c *gin.Context) {
 
 	// Create client
 	client := &ws.Client{
-		Hub:      hub,
-		Conn:     conn,
-		UserID:   userID,
-		Username: user.Username,
-		Send:     make(chan []byte, 256),
+		Hub:       hub,
+		Conn:      conn,
+		UserID:    userID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		Send:      make(chan []byte, 256),
 	}
 
 	hub.Register(client)
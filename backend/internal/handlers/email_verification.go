@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/mailer"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/services"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail confirms an email address with the token from the verification link.
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := services.VerifyEmail(database.DB, req.Token); err != nil {
+		if err == services.ErrInvalidVerificationToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link", "error_code": "VERIFICATION_TOKEN_INVALID"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified."})
+}
+
+// ResendVerificationEmail sends a new verification link to the caller's email.
+func ResendVerificationEmail(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	if user.Email == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You have no email address to verify"})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email address is already verified"})
+		return
+	}
+
+	sendVerificationEmail(user)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent."})
+}
+
+// sendVerificationEmail mails a verification link for the user's current
+// email. Failures are logged; the user can ask for another link.
+func sendVerificationEmail(user *models.User) {
+	if user.Email == nil {
+		return
+	}
+
+	token, err := services.CreateEmailVerification(database.DB, user)
+	if err != nil {
+		log.Printf("Failed to create email verification for %s: %v", user.Username, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", config.AppConfig.FrontendURL, url.QueryEscape(token))
+	mailer.SendAsync(mailer.Message{
+		To:      *user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address by opening the link below within %d hours:\n\n%s\n\n"+
+			"If you don't have an account with us, you can ignore this email.\n",
+			user.FullName, config.AppConfig.EmailVerificationTTL, link),
+	})
+}
+
+// emailChanged handles a saved email change: the old address is told about it
+// so a hijacked account doesn't go unnoticed, and the new one must be verified.
+func emailChanged(user *models.User, oldEmail *string) {
+	if oldEmail != nil && (user.Email == nil || *user.Email != *oldEmail) {
+		newAddress := "removed"
+		if user.Email != nil {
+			newAddress = "changed to " + *user.Email
+		}
+		mailer.SendAsync(mailer.Message{
+			To:      *oldEmail,
+			Subject: "Your email address was changed",
+			Body: fmt.Sprintf("Hi %s,\n\nThe email address on your account (%s) was %s. "+
+				"You won't receive account emails at this address anymore.\n\n"+
+				"If you didn't make this change, contact an administrator right away.\n",
+				user.FullName, user.Username, newAddress),
+		})
+	}
+
+	sendVerificationEmail(user)
+}
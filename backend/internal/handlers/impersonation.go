@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ImpersonationResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      *models.User `json:"user"`
+}
+
+// ImpersonationState describes an impersonation token for /api/me.
+type ImpersonationState struct {
+	ActorID       string    `json:"actor_id"`
+	ActorUsername string    `json:"actor_username"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// ImpersonateUser issues a short-lived token for acting as another user. It
+// can't be refreshed; logging out with it only ends the impersonation.
+func ImpersonateUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var target models.User
+	if err := database.DB.Where("id = ?", userID).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	actor := c.MustGet("user").(*models.User)
+
+	token, err := services.StartImpersonation(database.DB, actor, &target, c.GetString("session_id"), c.ClientIP())
+	if err != nil {
+		switch err {
+		case services.ErrImpersonateSelf:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		case services.ErrImpersonateInactive:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Inactive users cannot be impersonated"})
+		case services.ErrImpersonatePrivileged:
+			c.JSON(http.StatusForbidden, gin.H{"error": "User has permissions you don't have"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		}
+		return
+	}
+
+	database.DB.Preload("Role").First(&target, target.ID)
+	target.PasswordHash = ""
+
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		Token:     token.AccessToken,
+		ExpiresAt: token.ExpiresAt,
+		User:      &target,
+	})
+}
+
+// GetAuditLogs lists audit rows, newest first. Filter with actor_id and user_id.
+func GetAuditLogs(c *gin.Context) {
+	var actorID, userID *uuid.UUID
+	if raw := c.Query("actor_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor ID"})
+			return
+		}
+		actorID = &id
+	}
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = &id
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	logs, err := services.ListAuditLogs(database.DB, actorID, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// impersonating reports whether the request uses an impersonation token.
+func impersonating(c *gin.Context) bool {
+	_, ok := c.Get("impersonator")
+	return ok
+}
+
+// impersonationState returns the /api/me view of the current token, or nil
+// when the caller isn't impersonating.
+func impersonationState(c *gin.Context) *ImpersonationState {
+	claimsInterface, exists := c.Get("claims")
+	if !exists {
+		return nil
+	}
+	claims := claimsInterface.(*utils.Claims)
+	if claims.Actor == nil {
+		return nil
+	}
+	return &ImpersonationState{
+		ActorID:       claims.Actor.UserID,
+		ActorUsername: claims.Actor.Username,
+		ExpiresAt:     claims.ExpiresAt.Time,
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateInvitationRequest struct {
+	// RoleID defaults to the viewer role
+	RoleID         uuid.UUID `json:"role_id"`
+	MaxUses        int       `json:"max_uses" binding:"omitempty,min=1,max=10000"`
+	ExpiresInHours int       `json:"expires_in_hours" binding:"omitempty,min=1,max=8760"`
+	Note           string    `json:"note" binding:"max=200"`
+}
+
+type CreateInvitationResponse struct {
+	Code       string            `json:"code"`
+	Invitation models.Invitation `json:"invitation"`
+}
+
+func GetInvitations(c *gin.Context) {
+	invitations, err := services.ListInvitations(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// CreateInvitation creates an invitation code. It is single-use and valid for
+// a week unless max_uses or expires_in_hours say otherwise.
+func CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roleID := req.RoleID
+	if roleID == uuid.Nil {
+		var viewerRole models.Role
+		if err := database.DB.Where("name = ?", "viewer").First(&viewerRole).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role_id is required"})
+			return
+		}
+		roleID = viewerRole.ID
+	}
+
+	// Inviting hands the role out just like creating the user would
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserCreate, nil, []uuid.UUID{roleID}, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	expiresInHours := req.ExpiresInHours
+	if expiresInHours == 0 {
+		expiresInHours = 7 * 24
+	}
+	expiresAt := time.Now().Add(time.Duration(expiresInHours) * time.Hour)
+
+	user := c.MustGet("user").(*models.User)
+
+	code, invitation, err := services.CreateInvitation(database.DB, user, roleID, maxUses, &expiresAt, strings.TrimSpace(req.Note))
+	if err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		case services.ErrRoleNotHeld:
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only invite to roles whose permissions you hold yourself"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateInvitationResponse{
+		Code:       code,
+		Invitation: *invitation,
+	})
+}
+
+func RevokeInvitation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := services.RevokeInvitation(database.DB, id); err != nil {
+		if err == services.ErrInvitationNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
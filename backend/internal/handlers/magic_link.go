@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/mailer"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type SetRoleMagicLinkRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// RequestMagicLink emails a login link to a verified address whose role allows it.
+func RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same response whatever happens, so this can't be used to probe for accounts
+	response := gin.H{"message": "If magic links are enabled for that address, a login link has been sent."}
+
+	user, err := services.MagicLinkUser(database.DB, normalizeEmail(req.Email))
+	if err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := services.CreateMagicLink(database.DB, user)
+	if err != nil {
+		log.Printf("Failed to create magic link for %s: %v", user.Username, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := fmt.Sprintf("%s/auth/login?magic_token=%s", config.AppConfig.FrontendURL, url.QueryEscape(token))
+	mailer.SendAsync(mailer.Message{
+		To:      *user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %d minutes to log in. It only works once.\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.FullName, config.AppConfig.MagicLinkTTL, link),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyMagicLink takes the place of the password step: the link is used up
+// and the same captcha as after a password check is returned, followed by 2FA
+// where it applies.
+func VerifyMagicLink(c *gin.Context) {
+	var req VerifyMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempt := models.LoginAttempt{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	user, err := services.ConsumeMagicLink(database.DB, req.Token)
+	if err != nil {
+		if err == services.ErrInvalidMagicLink || err == services.ErrMagicLinkNotForYou {
+			attempt.Reason = services.LoginReasonInvalidLink
+			services.RecordLoginAttempt(database.DB, attempt)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login link is invalid or has expired", "error_code": "MAGIC_LINK_INVALID"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return
+	}
+
+	attempt.UserID = &user.ID
+	attempt.Username = user.Username
+	attempt.Success = true
+	services.RecordLoginAttempt(database.DB, attempt)
+
+	captcha, err := captchaStore.GenerateFor(user.ID, user.Username, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate captcha"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Message:         "Login link verified. Please solve the captcha.",
+		RequiresCaptcha: true,
+		CaptchaID:       captcha.ID,
+		CaptchaQuestion: captcha.Question,
+		CaptchaType:     captcha.Type,
+		Username:        user.Username,
+	})
+}
+
+func SetRoleMagicLink(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req SetRoleMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.Where("id = ?", roleID).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleUpdate, &role, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := database.DB.Model(&role).Update("allow_magic_link", *req.Enabled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
@@ -3,10 +3,16 @@ package handlers
 import (
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/services"
 	"admin-dashboard/internal/utils"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func GetCurrentUser(c *gin.Context) {
@@ -25,8 +31,8 @@ func GetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	// Load role
-	if err := database.DB.Preload("Role").Preload("Role.Permissions").First(user, user.ID).Error; err != nil {
+	// Load roles
+	if err := database.DB.Preload("Role").Preload("Role.Permissions").Preload("Roles").First(user, user.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
 		return
 	}
@@ -34,7 +40,174 @@ func GetCurrentUser(c *gin.Context) {
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, gin.H{
-		"user":        user,
-		"permissions": permissions,
+		"user":          user,
+		"permissions":   permissions,
+		"impersonation": impersonationState(c),
 	})
 }
+
+type UpdateProfileRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1,max=100"`
+	Email    *string `json:"email"` // "" clears the address
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type DeactivateAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateProfile lets users edit their own profile. Role and active flag are
+// deliberately not part of the request; those stay with USER_UPDATE.
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	updates := map[string]interface{}{}
+	oldEmail := copyEmail(user.Email)
+	emailUpdated := false
+	if req.FullName != nil {
+		fullName := strings.TrimSpace(*req.FullName)
+		if fullName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Full name cannot be empty"})
+			return
+		}
+		updates["full_name"] = fullName
+	}
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		if email == "" {
+			updates["email"] = nil
+			emailUpdated = oldEmail != nil
+		} else if oldEmail == nil || email != *oldEmail {
+			if !validEmail(email) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+				return
+			}
+			if emailTaken(email, user.ID) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+				return
+			}
+			updates["email"] = email
+			emailUpdated = true
+		}
+		if emailUpdated {
+			updates["email_verified"] = false
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
+	// Load role for response
+	if err := database.DB.Preload("Role").First(user, user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	user.PasswordHash = ""
+
+	if emailUpdated {
+		emailChanged(user, oldEmail)
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangeOwnPassword changes the caller's password after checking the current
+// one. Every other session is signed out; the current one stays valid.
+func ChangeOwnPassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	if !checkOwnPassword(c, user, req.CurrentPassword) {
+		return
+	}
+
+	if err := services.ChangePassword(database.DB, user, req.NewPassword); err != nil {
+		respondPasswordError(c, err, "Failed to change password")
+		return
+	}
+
+	sessionID := c.GetString("session_id")
+	if err := services.RevokeOtherSessions(database.DB, user.ID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed but other sessions could not be signed out"})
+		return
+	}
+	currentSession, _ := uuid.Parse(sessionID)
+	hub.CloseUserSessions(user.ID, currentSession)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// DeactivateOwnAccount deactivates the caller's account and ends all of their sessions.
+func DeactivateOwnAccount(c *gin.Context) {
+	var req DeactivateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	if !checkOwnPassword(c, user, req.Password) {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return services.RevokeAllUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate account"})
+		return
+	}
+	hub.CloseUserSessions(user.ID, uuid.Nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated successfully"})
+}
+
+// checkOwnPassword verifies a password re-entered by the logged-in user.
+// Wrong guesses count towards the login lockout.
+func checkOwnPassword(c *gin.Context, user *models.User, password string) bool {
+	if wait, _ := services.LoginRetryAfter(user); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts. Please wait before trying again."})
+		return false
+	}
+
+	if !utils.CheckPasswordHash(password, user.PasswordHash) {
+		if err := services.RecordFailedLogin(database.DB, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify password"})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return false
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := services.ResetFailedLogins(database.DB, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify password"})
+			return false
+		}
+	}
+	return true
+}
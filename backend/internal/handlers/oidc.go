@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/oidc"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	oidcStates = services.NewOIDCStateStore()
+	// ssoLogins holds provider logins until the frontend completes them with
+	// OIDCComplete, which goes on to the same second factor step as passwords
+	ssoLogins = services.NewMFAStore()
+)
+
+const (
+	// oidcStateCookie ties a login to the browser that started it: it holds the
+	// hash of the state, so a callback carrying someone else's state is refused
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+	oidcStateCookieAge  = 10 * 60
+)
+
+type OIDCCompleteRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// OIDCLogin redirects the browser to the identity provider.
+func OIDCLogin(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	state, login, err := oidcStates.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	setOIDCStateCookie(c, utils.HashToken(state), oidcStateCookieAge)
+	c.Redirect(http.StatusFound, oidc.Default.AuthCodeURL(state, login.Nonce, login.CodeVerifier))
+}
+
+// OIDCCallback handles the provider's redirect back, provisions the user and
+// hands the frontend a single-use code in the URL fragment (never sent to
+// servers). No tokens are issued here: 2FA still applies to SSO users.
+func OIDCCallback(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	if idpError := c.Query("error"); idpError != "" {
+		redirectSSOError(c, idpError)
+		return
+	}
+
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if subtle.ConstantTimeCompare([]byte(cookie), []byte(utils.HashToken(state))) != 1 {
+		redirectSSOError(c, "invalid_state")
+		return
+	}
+
+	login, err := oidcStates.Consume(state)
+	if err != nil {
+		redirectSSOError(c, "invalid_state")
+		return
+	}
+
+	claims, err := oidc.Default.Exchange(c.Request.Context(), c.Query("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		redirectSSOError(c, "exchange_failed")
+		return
+	}
+
+	user, err := services.ProvisionOIDCUser(database.DB, claims)
+	if err != nil {
+		if err == services.ErrNoMappedRole {
+			redirectSSOError(c, "no_role")
+			return
+		}
+		log.Printf("OIDC provisioning failed: %v", err)
+		redirectSSOError(c, "provisioning_failed")
+		return
+	}
+	if !user.IsActive {
+		redirectSSOError(c, "inactive")
+		return
+	}
+
+	code, err := ssoLogins.Create(user.ID, false, false)
+	if err != nil {
+		redirectSSOError(c, "session_failed")
+		return
+	}
+
+	fragment := url.Values{}
+	fragment.Set("code", code)
+	c.Redirect(http.StatusFound, config.AppConfig.FrontendURL+"/auth/sso-callback#"+fragment.Encode())
+}
+
+// OIDCComplete exchanges the code from OIDCCallback for the next login step:
+// tokens, or the second factor for users who have or need 2FA.
+func OIDCComplete(c *gin.Context) {
+	var req OIDCCompleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pending, ok := ssoLogins.Get(req.Code)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired single sign-on. Please try again."})
+		return
+	}
+	ssoLogins.Consume(req.Code)
+
+	var user models.User
+	if err := database.DB.Preload("Role").Where("id = ? AND is_active = ?", pending.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+		return
+	}
+
+	continueLogin(c, &user, "Signed in with single sign-on.", false)
+}
+
+// setOIDCStateCookie sets the state cookie, or clears it with a negative maxAge.
+// It has to be SameSite=Lax, not Strict, to come back on the provider's redirect.
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := strings.HasPrefix(config.AppConfig.OIDCRedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, oidcStateCookiePath, "", secure, true)
+}
+
+func redirectSSOError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, config.AppConfig.FrontendURL+"/auth/login?sso_error="+url.QueryEscape(code))
+}
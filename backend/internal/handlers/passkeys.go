@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/webauthn"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var passkeyCeremonies *services.PasskeyCeremonyStore
+
+func init() {
+	passkeyCeremonies = services.NewPasskeyCeremonyStore()
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string                         `json:"name" binding:"max=100"`
+	Credential *webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type BeginPasskeyLoginRequest struct {
+	Username string `json:"username"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	Credential *webauthn.AssertionResponse `json:"credential" binding:"required"`
+}
+
+func BeginPasskeyRegistration(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	options, err := services.BeginPasskeyRegistration(database.DB, passkeyCeremonies, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func FinishPasskeyRegistration(c *gin.Context) {
+	var req FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	credential, err := services.FinishPasskeyRegistration(database.DB, passkeyCeremonies, user, req.Name, req.Credential)
+	if err != nil {
+		switch err {
+		case services.ErrPasskeyInvalid:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey registration could not be verified"})
+		case services.ErrPasskeyExists:
+			c.JSON(http.StatusConflict, gin.H{"error": "This passkey is already registered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register passkey"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+func GetPasskeys(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	credentials, err := services.ListPasskeys(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+func RenamePasskey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	var req RenamePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	credential, err := services.RenamePasskey(database.DB, user.ID, id, req.Name)
+	if err != nil {
+		if err == services.ErrPasskeyNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+func DeletePasskey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	if err := services.DeletePasskey(database.DB, user.ID, id); err != nil {
+		if err == services.ErrPasskeyNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+// BeginPasskeyLogin starts a passwordless login. The username is optional.
+func BeginPasskeyLogin(c *gin.Context) {
+	var req BeginPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, err := services.BeginPasskeyLogin(database.DB, passkeyCeremonies, req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishPasskeyLogin verifies the passkey and skips the captcha step. If the
+// authenticator didn't verify the user (no PIN or biometrics), users with 2FA
+// still have to enter their code.
+func FinishPasskeyLogin(c *gin.Context) {
+	var req FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempt := models.LoginAttempt{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	user, userVerified, err := services.FinishPasskeyLogin(database.DB, passkeyCeremonies, req.Credential)
+	if err != nil {
+		if err == services.ErrPasskeyInvalid {
+			attempt.Reason = services.LoginReasonInvalidPasskey
+			services.RecordLoginAttempt(database.DB, attempt)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey could not be verified"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return
+	}
+
+	attempt.UserID = &user.ID
+	attempt.Username = user.Username
+	attempt.Success = true
+	services.RecordLoginAttempt(database.DB, attempt)
+
+	if userVerified {
+		completeLogin(c, user, nil, false)
+		return
+	}
+	continueLogin(c, user, "Passkey verified.", false)
+}
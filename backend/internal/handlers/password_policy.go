@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdatePasswordPolicyRequest struct {
+	MinLength     *int  `json:"min_length" binding:"omitempty,min=4,max=128"`
+	RequireUpper  *bool `json:"require_upper"`
+	RequireLower  *bool `json:"require_lower"`
+	RequireDigit  *bool `json:"require_digit"`
+	RequireSymbol *bool `json:"require_symbol"`
+	BlockCommon   *bool `json:"block_common"`
+	HistoryCount  *int  `json:"history_count" binding:"omitempty,min=0,max=24"`
+	MaxAgeDays    *int  `json:"max_age_days" binding:"omitempty,min=0,max=3650"`
+}
+
+func GetPasswordPolicy(c *gin.Context) {
+	policy, err := services.GetPasswordPolicy(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch password policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func UpdatePasswordPolicy(c *gin.Context) {
+	var req UpdatePasswordPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := services.GetPasswordPolicy(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch password policy"})
+		return
+	}
+
+	if req.MinLength != nil {
+		policy.MinLength = *req.MinLength
+	}
+	if req.RequireUpper != nil {
+		policy.RequireUpper = *req.RequireUpper
+	}
+	if req.RequireLower != nil {
+		policy.RequireLower = *req.RequireLower
+	}
+	if req.RequireDigit != nil {
+		policy.RequireDigit = *req.RequireDigit
+	}
+	if req.RequireSymbol != nil {
+		policy.RequireSymbol = *req.RequireSymbol
+	}
+	if req.BlockCommon != nil {
+		policy.BlockCommon = *req.BlockCommon
+	}
+	if req.HistoryCount != nil {
+		policy.HistoryCount = *req.HistoryCount
+	}
+	if req.MaxAgeDays != nil {
+		policy.MaxAgeDays = *req.MaxAgeDays
+	}
+
+	if err := database.DB.Save(policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// respondPasswordError writes a 400 with the broken rules for policy
+// violations, or a 500 with the given message for anything else.
+func respondPasswordError(c *gin.Context, err error, message string) {
+	if policyErr, ok := services.IsPasswordPolicyError(err); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Password does not meet the password policy",
+			"error_code": "PASSWORD_POLICY",
+			"violations": policyErr.Violations,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
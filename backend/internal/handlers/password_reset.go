@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/mailer"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/services"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same response whether or not the address exists, so this can't be used to probe for accounts
+	response := gin.H{"message": "If an account with that email exists, a reset link has been sent."}
+
+	var user models.User
+	if err := database.DB.Where("email = ? AND email_verified = ? AND is_active = ?", normalizeEmail(req.Email), true, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := services.CreatePasswordReset(database.DB, &user)
+	if err != nil {
+		log.Printf("Failed to create password reset for %s: %v", user.Username, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := fmt.Sprintf("%s/auth/reset-password?token=%s", config.AppConfig.FrontendURL, url.QueryEscape(token))
+	mailer.SendAsync(mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. "+
+			"If that was you, open the link below within %d minutes:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.FullName, config.AppConfig.PasswordResetTTL, link),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.ResetPassword(database.DB, req.Token, req.Password)
+	if err != nil {
+		if err == services.ErrInvalidResetToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token", "error_code": "RESET_TOKEN_INVALID"})
+			return
+		}
+		respondPasswordError(c, err, "Failed to reset password")
+		return
+	}
+
+	hub.CloseUserSessions(user.ID, uuid.Nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in with your new password."})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/middlewares"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EvaluatePolicyRequest struct {
+	Action string `json:"action" binding:"required"`
+	// ActorID defaults to the caller; evaluating for someone else needs ROLE_MANAGE
+	ActorID *uuid.UUID `json:"actor_id"`
+	// TargetUserID needs USER_READ, since the response shows the user's access
+	TargetUserID  *uuid.UUID  `json:"target_user_id"`
+	TargetRoleID  *uuid.UUID  `json:"target_role_id"`
+	RoleIDs       []uuid.UUID `json:"role_ids"`
+	PermissionIDs []uuid.UUID `json:"permission_ids"`
+	Rank          *int        `json:"rank"`
+}
+
+type PolicyRuleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Actions     []string `json:"actions"`
+}
+
+// authorize checks a request against the policy engine and responds with 403
+// when it is denied. Handlers call it after loading the target and before
+// changing anything.
+func authorize(c *gin.Context, req policy.Request, buildErr error) bool {
+	if buildErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return false
+	}
+
+	actor := c.MustGet("user").(*models.User)
+	subject, err := policySubject(c, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return false
+	}
+	req.Actor = subject
+	decision := policy.Default.Evaluate(req)
+	if !decision.Allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      "Not allowed: " + decision.Reason,
+			"error_code": "POLICY_DENIED",
+			"rule":       decision.DeniedBy,
+		})
+		return false
+	}
+	return true
+}
+
+// policySubject describes actor to the policy engine. When actor is the caller
+// and the request uses an API key, only the key's scopes count as permissions.
+func policySubject(c *gin.Context, actor *models.User) (policy.Subject, error) {
+	subject, err := services.PolicySubject(database.DB, actor)
+	if err != nil {
+		return subject, err
+	}
+	caller := c.MustGet("user").(*models.User)
+	if scopes, ok := c.Get("api_key_scopes"); ok && actor.ID == caller.ID {
+		subject = services.ScopeSubject(subject, scopes.([]string))
+	}
+	return subject, nil
+}
+
+// GetPolicyRules lists the rules of the policy engine.
+func GetPolicyRules(c *gin.Context) {
+	rules := policy.Default.Rules()
+	response := make([]PolicyRuleResponse, len(rules))
+	for i, rule := range rules {
+		actions := rule.Actions
+		if len(actions) == 0 {
+			actions = policy.Actions
+		}
+		response[i] = PolicyRuleResponse{Name: rule.Name, Description: rule.Description, Actions: actions}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// EvaluatePolicy is a dry run: it reports what the policy engine would decide
+// for a request, rule by rule, without changing anything.
+func EvaluatePolicy(c *gin.Context) {
+	var req EvaluatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validPolicyAction(req.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action", "actions": policy.Actions})
+		return
+	}
+
+	caller := c.MustGet("user").(*models.User)
+	actor := caller
+	if req.ActorID != nil && *req.ActorID != caller.ID {
+		if !middlewares.HasPermission(c, caller, "ROLE_MANAGE") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		actor = &models.User{}
+		if err := database.DB.Where("id = ?", *req.ActorID).First(actor).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Actor not found"})
+			return
+		}
+	}
+
+	permissionNames, err := services.PermissionNames(database.DB, req.PermissionIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	var policyReq policy.Request
+	switch req.Action {
+	case policy.ActionUserCreate, policy.ActionUserUpdate, policy.ActionUserDelete:
+		var target *models.User
+		if req.TargetUserID != nil {
+			if !middlewares.HasPermission(c, caller, "USER_READ") {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+				return
+			}
+			target = &models.User{}
+			if err := database.DB.Where("id = ?", *req.TargetUserID).First(target).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+		}
+		policyReq, err = services.UserPolicyRequest(database.DB, req.Action, target, req.RoleIDs, permissionNames)
+	default:
+		var target *models.Role
+		if req.TargetRoleID != nil {
+			target = &models.Role{}
+			if err := database.DB.Where("id = ?", *req.TargetRoleID).First(target).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+				return
+			}
+		}
+		policyReq, err = services.RolePolicyRequest(database.DB, req.Action, target, req.Rank, permissionNames)
+		if err == nil && len(req.RoleIDs) > 0 {
+			// Roles handed out as part of a role change, e.g. a role deletion's reassign_to
+			var ranks []int
+			ranks, _, err = services.RoleGrant(database.DB, req.RoleIDs)
+			policyReq.GrantedRanks = append(policyReq.GrantedRanks, ranks...)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return
+	}
+
+	policyReq.Actor, err = policySubject(c, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return
+	}
+	decision := policy.Default.Evaluate(policyReq)
+
+	c.JSON(http.StatusOK, gin.H{
+		"request":  policyReq,
+		"decision": decision,
+	})
+}
+
+func validPolicyAction(action string) bool {
+	for _, a := range policy.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
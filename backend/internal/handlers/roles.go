@@ -3,12 +3,24 @@ package handlers
 import (
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+func init() {
+	permissions.Register(permissions.Definition{
+		Name:         "ROLE_MANAGE",
+		Description:  "Manage roles and permissions",
+		DefaultRoles: []string{"admin"},
+	})
+}
+
 func GetRoles(c *gin.Context) {
 	var roles []models.Role
 	if err := database.DB.Find(&roles).Error; err != nil {
@@ -28,14 +40,23 @@ func GetRolePermissions(c *gin.Context) {
 	}
 
 	var role models.Role
-	if err := database.DB.Preload("Permissions").Where("id = ?", roleID).First(&role).Error; err != nil {
+	if err := database.DB.Preload("Permissions").Preload("Parent").Where("id = ?", roleID).First(&role).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
 		return
 	}
 
+	effective, err := services.RolePermissionSources(database.DB, role.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	// permissions are the role's own, which AssignRolePermissions replaces;
+	// effective_permissions add the inherited ones, labelled with their source
 	c.JSON(http.StatusOK, gin.H{
-		"role":        role,
-		"permissions": role.Permissions,
+		"role":                  role,
+		"permissions":           role.Permissions,
+		"effective_permissions": effective,
 	})
 }
 
@@ -76,6 +97,15 @@ func AssignRolePermissions(c *gin.Context) {
 		return
 	}
 
+	names := make([]string, len(permissions))
+	for i, perm := range permissions {
+		names[i] = perm.Name
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleUpdate, &role, nil, names)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
 	// Assign permissions to role
 	if err := database.DB.Model(&role).Association("Permissions").Replace(permissions); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign permissions"})
@@ -91,3 +121,204 @@ func AssignRolePermissions(c *gin.Context) {
 		"permissions": role.Permissions,
 	})
 }
+
+type CreateRoleRequest struct {
+	Name          string      `json:"name" binding:"required,max=64"`
+	Description   string      `json:"description"`
+	Rank          int         `json:"rank"`
+	ParentID      *uuid.UUID  `json:"parent_id"`
+	PermissionIDs []uuid.UUID `json:"permission_ids"`
+}
+
+type SetRoleParentRequest struct {
+	// ParentID is null for a role that inherits nothing
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type UpdateRoleRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=64"`
+	Description *string `json:"description"`
+	Rank        *int    `json:"rank"`
+}
+
+func CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The new role hands out its own permissions and everything it inherits
+	granted, err := services.PermissionNames(database.DB, req.PermissionIDs)
+	if err == nil && req.ParentID != nil {
+		var inherited []string
+		inherited, err = utils.GetRolePermissionNames(database.DB, *req.ParentID)
+		granted = append(granted, inherited...)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleCreate, nil, &req.Rank, granted)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	role, err := services.CreateRole(database.DB, req.Name, req.Description, req.Rank, req.ParentID, req.PermissionIDs)
+	if err != nil {
+		respondRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+func UpdateRole(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, ok := findRole(c, roleID)
+	if !ok {
+		return
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleUpdate, target, req.Rank, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	role, err := services.UpdateRole(database.DB, roleID, req.Name, req.Description, req.Rank)
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role. Users still holding it must be moved to another
+// role, given as ?reassign_to=<role id>.
+func DeleteRole(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var reassignTo *uuid.UUID
+	if raw := c.Query("reassign_to"); raw != "" {
+		target, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to role ID"})
+			return
+		}
+		reassignTo = &target
+	}
+
+	role, ok := findRole(c, roleID)
+	if !ok {
+		return
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleDelete, role, nil, nil)
+	if err == nil && reassignTo != nil {
+		// Moving the role's users hands out the reassign_to role
+		var ranks []int
+		ranks, _, err = services.RoleGrant(database.DB, []uuid.UUID{*reassignTo})
+		policyReq.GrantedRanks = append(policyReq.GrantedRanks, ranks...)
+	}
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	moved, err := services.DeleteRole(database.DB, roleID, reassignTo)
+	if err != nil {
+		respondRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Role deleted successfully",
+		"users_moved": moved,
+	})
+}
+
+// SetRoleParent sets or clears the role a role inherits permissions from.
+func SetRoleParent(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req SetRoleParentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, ok := findRole(c, roleID)
+	if !ok {
+		return
+	}
+	var inherited []string
+	if req.ParentID != nil {
+		inherited, err = utils.GetRolePermissionNames(database.DB, *req.ParentID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return
+	}
+	policyReq, err := services.RolePolicyRequest(database.DB, policy.ActionRoleUpdate, target, nil, inherited)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	role, err := services.SetRoleParent(database.DB, roleID, req.ParentID)
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// findRole loads a role, responding with 404 when it doesn't exist.
+func findRole(c *gin.Context, roleID uuid.UUID) (*models.Role, bool) {
+	var role models.Role
+	if err := database.DB.Where("id = ?", roleID).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return nil, false
+	}
+	return &role, true
+}
+
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case services.ErrRoleNameRequired, services.ErrParentRoleNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case services.ErrRoleCycle:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "error_code": "ROLE_CYCLE"})
+	case services.ErrPermissionNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more permissions not found"})
+	case services.ErrRoleNameTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "error_code": "ROLE_EXISTS"})
+	case services.ErrRoleBuiltIn:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "ROLE_BUILT_IN"})
+	case services.ErrRoleInUse:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "error_code": "ROLE_IN_USE"})
+	case services.ErrRoleReassignTarget:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": "ROLE_REASSIGN_INVALID"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
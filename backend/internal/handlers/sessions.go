@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionResponse is a session as shown in device lists. Current marks the
+// session the request itself was made with. ID is the session ID for the
+// owner and its handle for anyone else.
+type SessionResponse struct {
+	models.Session
+	ID      string `json:"id"`
+	Current bool   `json:"current"`
+}
+
+// sessionMeta captures the client details stored with a new or refreshed session.
+func sessionMeta(c *gin.Context) services.SessionMeta {
+	return services.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+func GetMySessions(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	listSessions(c, user.ID)
+}
+
+func RevokeMySession(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+	revokeSession(c, user.ID, sessionID)
+}
+
+func GetUserSessions(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Check if user exists
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	listSessions(c, userID)
+}
+
+func RevokeUserSession(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, &user, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	// Other users' sessions are addressed by the handle they are listed with
+	sessionID, err := services.FindSessionByHandle(database.DB, userID, c.Param("handle"))
+	if err != nil {
+		if err == services.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	revokeSession(c, userID, sessionID)
+}
+
+func listSessions(c *gin.Context, userID uuid.UUID) {
+	sessions, err := services.ListActiveSessions(database.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	currentID := c.GetString("session_id")
+	owner := isSessionOwner(c, userID)
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		id := services.SessionHandle(session.ID)
+		if owner {
+			id = session.ID.String()
+		}
+		response[i] = SessionResponse{
+			Session: session,
+			ID:      id,
+			Current: session.ID.String() == currentID,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// isSessionOwner reports whether the request was made by the user themselves,
+// signed in with one of their sessions rather than impersonated or with an API key.
+func isSessionOwner(c *gin.Context, userID uuid.UUID) bool {
+	user := c.MustGet("user").(*models.User)
+	return user.ID == userID && !impersonating(c) && c.GetString("session_id") != ""
+}
+
+// revokeSession ends one of the user's sessions and disconnects its live chat connections.
+func revokeSession(c *gin.Context, userID, sessionID uuid.UUID) {
+	if err := services.RevokeUserSession(database.DB, userID, sessionID); err != nil {
+		if err == services.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	hub.CloseSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
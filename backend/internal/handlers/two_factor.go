@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFASetupRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type SetRoleRequire2FARequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// SetupTOTPForLogin starts enrollment for a user whose role requires 2FA, in the middle of logging in.
+func SetupTOTPForLogin(c *gin.Context) {
+	var req MFASetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, ok := mfaStore.Get(req.MFAToken)
+	if !ok || !challenge.Enrolling {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor session"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_active = ?", challenge.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+		return
+	}
+
+	secret, uri, err := services.BeginTOTPEnrollment(database.DB, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+// VerifyTOTPForLogin completes a login with a TOTP or recovery code. For users
+// enrolling during login, the code also confirms the new authenticator.
+func VerifyTOTPForLogin(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The attempt is used up before the code is checked
+	challenge, ok := mfaStore.UseAttempt(req.MFAToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor session"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Role").Where("id = ? AND is_active = ?", challenge.UserID, true).First(&user).Error; err != nil {
+		mfaStore.Consume(req.MFAToken)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+		return
+	}
+
+	var recoveryCodes []string
+	var err error
+	if challenge.Enrolling {
+		recoveryCodes, err = services.ConfirmTOTPEnrollment(database.DB, &user, req.Code)
+	} else {
+		err = services.VerifySecondFactor(database.DB, &user, req.Code)
+	}
+	if err != nil {
+		switch err {
+		case services.ErrInvalidTOTPCode:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		case services.ErrTOTPNotStarted:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		}
+		return
+	}
+
+	mfaStore.Consume(req.MFAToken)
+	completeLogin(c, &user, recoveryCodes, challenge.PasswordChange)
+}
+
+func SetupTOTP(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	secret, uri, err := services.BeginTOTPEnrollment(database.DB, user)
+	if err != nil {
+		if err == services.ErrTOTPAlreadyEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+func EnableTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	codes, err := services.ConfirmTOTPEnrollment(database.DB, user, req.Code)
+	if err != nil {
+		switch err {
+		case services.ErrTOTPAlreadyEnabled:
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		case services.ErrTOTPNotStarted:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		case services.ErrInvalidTOTPCode:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func DisableTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	if services.RoleRequires2FA(database.DB, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+		return
+	}
+
+	if !verifyOwnSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	if err := services.ResetTOTP(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*models.User)
+
+	if !verifyOwnSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTOTP lets an admin clear a user's 2FA, e.g. after they lost their device.
+func ResetUserTOTP(c *gin.Context) {
+	id := c.Param("id")
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, &user, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := services.ResetTOTP(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+func SetRoleRequire2FA(c *gin.Context) {
+	id := c.Param("id")
+	roleID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req SetRoleRequire2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.Where("id = ?", roleID).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if err := database.DB.Model(&role).Update("require_2fa", *req.Required).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// verifyOwnSecondFactor checks a code for the current user and writes the error response if it's wrong.
+func verifyOwnSecondFactor(c *gin.Context, user *models.User, code string) bool {
+	if err := services.VerifySecondFactor(database.DB, user, code); err != nil {
+		switch err {
+		case services.ErrTOTPNotEnabled:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		case services.ErrInvalidTOTPCode:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"admin-dashboard/internal/database"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
+	"admin-dashboard/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SetUserRolesRequest struct {
+	RoleIDs []uuid.UUID `json:"role_ids" binding:"required"`
+	// PrimaryRoleID defaults to the current primary role, if it is kept
+	PrimaryRoleID *uuid.UUID `json:"primary_role_id"`
+}
+
+type SetPermissionOverridesRequest struct {
+	Overrides []services.PermissionOverride `json:"overrides" binding:"required,dive"`
+}
+
+// UserAccessResponse explains where a user's permissions come from.
+type UserAccessResponse struct {
+	Roles                []models.Role                   `json:"roles"`
+	PrimaryRoleID        uuid.UUID                       `json:"primary_role_id"`
+	Overrides            []models.UserPermissionOverride `json:"overrides"`
+	EffectivePermissions []string                        `json:"effective_permissions"`
+}
+
+// GetUserAccess returns a user's roles, permission overrides and the
+// effective permissions that result.
+func GetUserAccess(c *gin.Context) {
+	user, ok := findUserParam(c)
+	if !ok {
+		return
+	}
+	respondUserAccess(c, user)
+}
+
+// SetUserRoles replaces the roles a user holds.
+func SetUserRoles(c *gin.Context) {
+	user, ok := findUserParam(c)
+	if !ok {
+		return
+	}
+
+	var req SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, user, req.RoleIDs, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := services.SetUserRoles(database.DB, user, req.RoleIDs, req.PrimaryRoleID); err != nil {
+		switch err {
+		case services.ErrRolesRequired, services.ErrInvalidRoles, services.ErrPrimaryRoleNotHeld:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+		}
+		return
+	}
+
+	database.DB.First(user, user.ID)
+	respondUserAccess(c, user)
+}
+
+// SetUserPermissionOverrides replaces a user's permission grants and denies.
+func SetUserPermissionOverrides(c *gin.Context) {
+	user, ok := findUserParam(c)
+	if !ok {
+		return
+	}
+
+	var req SetPermissionOverridesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	granted, err := overridePermissionsGranted(user.ID, req.Overrides)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy"})
+		return
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, user, nil, granted)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := services.SetPermissionOverrides(database.DB, user.ID, req.Overrides); err != nil {
+		switch err {
+		case services.ErrInvalidOverride, services.ErrDuplicateOverride, services.ErrOverridePermNotFound:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update permission overrides"})
+		}
+		return
+	}
+
+	respondUserAccess(c, user)
+}
+
+// overridePermissionsGranted returns the names of the permissions a change of
+// overrides can hand out: new grants, and current denies that are lifted.
+func overridePermissionsGranted(userID uuid.UUID, overrides []services.PermissionOverride) ([]string, error) {
+	current, err := services.ListPermissionOverrides(database.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	stillDenied := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, override := range overrides {
+		switch override.Effect {
+		case models.OverrideGrant:
+			ids = append(ids, override.PermissionID)
+		case models.OverrideDeny:
+			stillDenied[override.PermissionID] = true
+		}
+	}
+	for _, override := range current {
+		if override.Effect == models.OverrideDeny && !stillDenied[override.PermissionID] {
+			ids = append(ids, override.PermissionID)
+		}
+	}
+	return services.PermissionNames(database.DB, ids)
+}
+
+func findUserParam(c *gin.Context) (*models.User, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func respondUserAccess(c *gin.Context, user *models.User) {
+	if err := database.DB.Preload("Roles.Permissions").First(user, user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	overrides, err := services.ListPermissionOverrides(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permission overrides"})
+		return
+	}
+	effective, err := utils.GetUserPermissions(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserAccessResponse{
+		Roles:                user.Roles,
+		PrimaryRoleID:        user.RoleID,
+		Overrides:            overrides,
+		EffectivePermissions: effective,
+	})
+}
@@ -3,39 +3,68 @@ package handlers
 import (
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/services"
 	"admin-dashboard/internal/utils"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+func init() {
+	permissions.Register(
+		permissions.Definition{Name: "USER_CREATE", Description: "Create new users", DefaultRoles: []string{"admin", "manager"}},
+		permissions.Definition{Name: "USER_READ", Description: "View users", DefaultRoles: []string{"admin", "manager", "viewer"}},
+		permissions.Definition{Name: "USER_UPDATE", Description: "Update users", DefaultRoles: []string{"admin", "manager"}},
+		permissions.Definition{Name: "USER_DELETE", Description: "Delete/deactivate users", DefaultRoles: []string{"admin"}},
+	)
+}
+
 type CreateUserRequest struct {
 	FullName string    `json:"full_name" binding:"required"`
 	Username string    `json:"username" binding:"required,min=3,max=50"`
-	Password string    `json:"password" binding:"required,min=8"`
+	Email    string    `json:"email" binding:"omitempty,email"`
+	Password string    `json:"password" binding:"required"`
 	RoleID   uuid.UUID `json:"role_id" binding:"required"`
 	IsActive bool      `json:"is_active"`
+	// MustChangePassword makes the user pick their own password at first login
+	MustChangePassword bool `json:"must_change_password"`
 }
 
 type UpdateUserRequest struct {
 	FullName string    `json:"full_name"`
 	Username string    `json:"username"`
+	Email    *string   `json:"email"` // "" clears the address
 	Password string    `json:"password"`
 	RoleID   uuid.UUID `json:"role_id"`
 	IsActive *bool     `json:"is_active"`
+	// MustChangePassword forces a password change at the user's next login
+	MustChangePassword *bool `json:"must_change_password"`
 }
 
 func GetUsers(c *gin.Context) {
 	var users []models.User
-	if err := database.DB.Preload("Role").Find(&users).Error; err != nil {
+	if err := database.DB.Preload("Role").Preload("Roles").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
 
+	effective, err := utils.GetPermissionsForUsers(database.DB, users)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
 	// Clear password hashes
 	for i := range users {
 		users[i].PasswordHash = ""
+		users[i].EffectivePermissions = effective[users[i].ID]
 	}
 
 	c.JSON(http.StatusOK, users)
@@ -50,12 +79,19 @@ func GetUser(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := database.DB.Preload("Role").Preload("Role.Permissions").Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := database.DB.Preload("Role").Preload("Role.Permissions").Preload("Roles").Where("id = ?", userID).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	effective, err := utils.GetUserPermissions(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
 	user.PasswordHash = ""
+	user.EffectivePermissions = effective
 	c.JSON(http.StatusOK, user)
 }
 
@@ -73,36 +109,60 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	// Check if email already exists
+	email := normalizeEmail(req.Email)
+	if email != "" && emailTaken(email, uuid.Nil) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		return
+	}
+
 	// Verify role exists
 	var role models.Role
 	if err := database.DB.Where("id = ?", req.RoleID).First(&role).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
 		return
 	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserCreate, nil, []uuid.UUID{req.RoleID}, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	// Check the password policy and hash
+	hashedPassword, err := services.HashNewPassword(database.DB, req.Username, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondPasswordError(c, err, "Failed to hash password")
 		return
 	}
 
 	// Create user
+	now := time.Now()
 	user := models.User{
-		FullName:     req.FullName,
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
-		RoleID:       req.RoleID,
-		IsActive:     req.IsActive,
+		FullName:           req.FullName,
+		Username:           req.Username,
+		PasswordHash:       hashedPassword,
+		PasswordChangedAt:  &now,
+		MustChangePassword: req.MustChangePassword,
+		RoleID:             req.RoleID,
+		IsActive:           req.IsActive,
+	}
+	if email != "" {
+		user.Email = &email
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return services.RecordPasswordHistory(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	sendVerificationEmail(&user)
 
-	// Load role for response
-	database.DB.Preload("Role").First(&user, user.ID)
+	// Load roles for response
+	database.DB.Preload("Role").Preload("Roles").First(&user, user.ID)
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusCreated, user)
@@ -128,6 +188,14 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	var newRoleIDs []uuid.UUID
+	if req.RoleID != uuid.Nil {
+		newRoleIDs = []uuid.UUID{req.RoleID}
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, &user, newRoleIDs, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
 
 	// Update fields
 	if req.FullName != "" {
@@ -142,13 +210,28 @@ func UpdateUser(c *gin.Context) {
 		}
 		user.Username = req.Username
 	}
-	if req.Password != "" {
-		hashedPassword, err := utils.HashPassword(req.Password)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-			return
+	oldEmail := copyEmail(user.Email)
+	emailUpdated := false
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		if email == "" {
+			user.Email = nil
+			emailUpdated = oldEmail != nil
+		} else if oldEmail == nil || email != *oldEmail {
+			if !validEmail(email) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+				return
+			}
+			if emailTaken(email, userID) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+				return
+			}
+			user.Email = &email
+			emailUpdated = true
+		}
+		if emailUpdated {
+			user.EmailVerified = false
 		}
-		user.PasswordHash = hashedPassword
 	}
 	if req.RoleID != uuid.Nil {
 		// Verify role exists
@@ -157,16 +240,39 @@ func UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
 			return
 		}
-		user.RoleID = req.RoleID
 	}
 	if req.IsActive != nil {
 		user.IsActive = *req.IsActive
 	}
+	if req.Password != "" {
+		// Validates against the policy and history, and saves the new hash right away;
+		// done last so a rejected password leaves the other fields untouched
+		if err := services.ChangePassword(database.DB, &user, req.Password); err != nil {
+			respondPasswordError(c, err, "Failed to update password")
+			return
+		}
+	}
+	if req.MustChangePassword != nil {
+		user.MustChangePassword = *req.MustChangePassword
+	}
 
 	if err := database.DB.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	// role_id is the primary role; changing it swaps that role in the user's role set
+	if req.RoleID != uuid.Nil {
+		if err := services.ReplacePrimaryRole(database.DB, &user, req.RoleID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+			return
+		}
+	}
+	if !user.IsActive {
+		hub.CloseUserSessions(user.ID, uuid.Nil)
+	}
+	if emailUpdated {
+		emailChanged(&user, oldEmail)
+	}
 
 	// Load role for response
 	database.DB.Preload("Role").First(&user, user.ID)
@@ -189,6 +295,10 @@ func DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserDelete, &user, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
 
 	// Soft delete by deactivating
 	user.IsActive = false
@@ -196,6 +306,87 @@ func DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	hub.CloseUserSessions(user.ID, uuid.Nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
 }
+
+func RevokeUserTokens(c *gin.Context) {
+	id := c.Param("id")
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Check if user exists
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, &user, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := services.RevokeAllUserTokens(database.DB, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
+	hub.CloseUserSessions(userID, uuid.Nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "All tokens revoked successfully"})
+}
+
+func UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Check if user exists
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	policyReq, err := services.UserPolicyRequest(database.DB, policy.ActionUserUpdate, &user, nil, nil)
+	if !authorize(c, policyReq, err) {
+		return
+	}
+
+	if err := services.ResetFailedLogins(database.DB, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// copyEmail copies an email pointer, so the old address survives reloading the user.
+func copyEmail(email *string) *string {
+	if email == nil {
+		return nil
+	}
+	e := *email
+	return &e
+}
+
+// emailTaken reports whether another user (other than exceptID) already uses the address.
+func emailTaken(email string, exceptID uuid.UUID) bool {
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ? AND id != ?", email, exceptID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"admin-dashboard/internal/config"
+	"fmt"
+	"log"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var Default Mailer
+
+// Init picks the mailer implementation from config.
+func Init() error {
+	switch config.AppConfig.MailDriver {
+	case "smtp":
+		Default = NewSMTPMailer(
+			config.AppConfig.SMTPHost,
+			config.AppConfig.SMTPPort,
+			config.AppConfig.SMTPUsername,
+			config.AppConfig.SMTPPassword,
+			config.AppConfig.MailFrom,
+		)
+	case "file", "":
+		outbox, err := NewFileMailer(config.AppConfig.MailOutboxDir, config.AppConfig.MailFrom)
+		if err != nil {
+			return err
+		}
+		Default = outbox
+	default:
+		return fmt.Errorf("unknown mail driver %q", config.AppConfig.MailDriver)
+	}
+
+	log.Printf("Mailer initialized (%s)", config.AppConfig.MailDriver)
+	return nil
+}
+
+// SendAsync sends a message in the background so request latency doesn't
+// depend on (or reveal) mail delivery. Failures are only logged.
+func SendAsync(msg Message) {
+	if Default == nil {
+		log.Printf("Mailer not initialized, dropping email to %s", msg.To)
+		return
+	}
+	go func() {
+		if err := Default.Send(msg); err != nil {
+			log.Printf("Failed to send email to %s: %v", msg.To, err)
+		}
+	}()
+}
@@ -1,24 +1,18 @@
 package middlewares
 
 import (
-	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/database"
 	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
+	"admin-dashboard/internal/services"
 	"admin-dashboard/internal/utils"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	RoleID   string `json:"role_id"`
-	jwt.RegisteredClaims
-}
-
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -37,18 +31,37 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.AppConfig.JWTSecret), nil
-		})
+		if services.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		claims, err := utils.ParseJWT(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		// Reject tokens that were logged out
+		if claims.ID == "" || services.IsTokenRevoked(database.DB, claims.ID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Reject tokens whose session was revoked, expired or deleted.
+		// Impersonation tokens live on the impersonating admin's session.
+		sessionOwner := claims.UserID
+		if claims.Actor != nil {
+			sessionOwner = claims.Actor.UserID
+		}
+		if claims.SessionID == "" || !services.IsSessionActive(database.DB, claims.SessionID, sessionOwner) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			c.Abort()
+			return
+		}
+
 		// Check if user exists and is active
 		var user models.User
 		if err := database.DB.Where("id = ? AND is_active = ?", claims.UserID, true).First(&user).Error; err != nil {
@@ -57,17 +70,90 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		var impersonator *models.User
+		if claims.Actor != nil {
+			impersonator, err = services.GetImpersonator(database.DB, claims.Actor.UserID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation is no longer allowed"})
+				c.Abort()
+				return
+			}
+		}
+
+		// Keep the session's last-seen time current for the device list
+		if err := services.TouchSession(database.DB, claims.SessionID, c.ClientIP()); err != nil {
+			log.Printf("Failed to update session %s: %v", claims.SessionID, err)
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role_id", claims.RoleID)
+		c.Set("session_id", claims.SessionID)
+		c.Set("claims", claims)
 		c.Set("user", &user)
+		if impersonator != nil {
+			c.Set("impersonator", impersonator)
+		}
 
 		c.Next()
+
+		// Attribute everything done while impersonating to the real admin
+		if impersonator != nil && !isReadOnlyMethod(c.Request.Method) {
+			services.RecordAudit(database.DB, models.AuditLog{
+				ActorID: impersonator.ID,
+				UserID:  &user.ID,
+				Action:  services.AuditImpersonatedAction,
+				Method:  c.Request.Method,
+				Path:    c.Request.URL.Path,
+				Status:  c.Writer.Status(),
+				IP:      c.ClientIP(),
+			})
+		}
 	}
 }
 
+// RejectImpersonation blocks password and security changes made with an
+// impersonation token.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonator"); impersonating {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "This action is not allowed while impersonating",
+				"error_code": "IMPERSONATION_FORBIDDEN",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RejectAPIKeys blocks account and security changes made with an API key, so
+// a leaked key can't take over the account, lock its owner out or mint more keys.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, usingKey := c.Get("api_key_id"); usingKey {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "This action cannot be performed with an API key",
+				"error_code": "API_KEY_FORBIDDEN",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
 func RequirePermission(permissionName string) gin.HandlerFunc {
+	permissions.MustBeRegistered(permissionName)
+
 	return func(c *gin.Context) {
 		// First check authentication
 		userInterface, exists := c.Get("user")
@@ -77,11 +163,7 @@ func RequirePermission(permissionName string) gin.HandlerFunc {
 			return
 		}
 
-		user := userInterface.(*models.User)
-
-		// Check if user has the required permission
-		hasPermission := utils.UserHasPermission(database.DB, user.ID, permissionName)
-		if !hasPermission {
+		if !HasPermission(c, userInterface.(*models.User), permissionName) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
@@ -90,3 +172,50 @@ func RequirePermission(permissionName string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// HasPermission reports whether user has the permission, limited to the
+// scopes of the API key the request was made with, if any. Handlers use it
+// for permissions that only some requests need.
+func HasPermission(c *gin.Context, user *models.User, permissionName string) bool {
+	hasPermission := utils.UserHasPermission(database.DB, user.ID, permissionName)
+
+	// API keys are further limited to the permissions they were scoped to
+	if scopes, ok := c.Get("api_key_scopes"); ok && hasPermission {
+		hasPermission = false
+		for _, scope := range scopes.([]string) {
+			if scope == permissionName {
+				hasPermission = true
+				break
+			}
+		}
+	}
+	return hasPermission
+}
+
+// authenticateAPIKey authenticates a request made with a personal API key.
+func authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, err := services.AuthenticateAPIKey(database.DB, key)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+
+	// Check if user exists and is active
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_active = ?", apiKey.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+		c.Abort()
+		return
+	}
+
+	// Set user info in context
+	c.Set("user_id", user.ID.String())
+	c.Set("username", user.Username)
+	c.Set("role_id", user.RoleID.String())
+	c.Set("api_key_id", apiKey.ID.String())
+	c.Set("api_key_scopes", apiKey.PermissionNames())
+	c.Set("user", &user)
+
+	c.Next()
+}
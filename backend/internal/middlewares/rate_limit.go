@@ -15,54 +15,91 @@ type rateLimiter struct {
 }
 
 type visitor struct {
-	lastSeen time.Time
-	count    int
+	windowStart time.Time
+	lastSeen    time.Time
+	count       int
 }
 
-var limiter = &rateLimiter{
-	visitors: make(map[string]*visitor),
-	rate:     time.Minute,
-	burst:    5,
-}
+var (
+	limiters   = make(map[string]*rateLimiter)
+	limitersMu sync.Mutex
+)
 
-func RateLimitMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		limiter.mu.Lock()
+// RateLimitMiddleware allows each IP burst requests per rate period on the
+// routes sharing name. Every name has its own counts, so one route's traffic
+// doesn't use up another's.
+func RateLimitMiddleware(name string, rate time.Duration, burst int) gin.HandlerFunc {
+	limiter := newRateLimiter(name, rate, burst)
 
-		v, exists := limiter.visitors[ip]
-		if !exists {
-			v = &visitor{
-				lastSeen: time.Now(),
-				count:    1,
-			}
-			limiter.visitors[ip] = v
-			limiter.mu.Unlock()
-			c.Next()
+	return func(c *gin.Context) {
+		if !limiter.allow(c.ClientIP()) {
+			c.JSON(429, gin.H{"error": "Too many requests. Please try again later."})
+			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Reset count if rate period has passed
-		if time.Since(v.lastSeen) > limiter.rate {
-			v.count = 1
-			v.lastSeen = time.Now()
-			limiter.mu.Unlock()
-			c.Next()
-			return
-		}
+// newRateLimiter returns the limiter registered under name, creating it on first use.
+func newRateLimiter(name string, rate time.Duration, burst int) *rateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if rl, exists := limiters[name]; exists {
+		return rl
+	}
+	rl := &rateLimiter{
+		visitors: make(map[string]*visitor),
+		rate:     rate,
+		burst:    burst,
+	}
+	limiters[name] = rl
+	go rl.cleanup()
+	return rl
+}
 
-		// Increment count
-		v.count++
-		v.lastSeen = time.Now()
+// allow counts a request from ip. Only allowed requests move lastSeen, so a
+// client that keeps retrying while blocked is let back in once the period ends.
+func (rl *rateLimiter) allow(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-		if v.count > limiter.burst {
-			limiter.mu.Unlock()
-			c.JSON(429, gin.H{"error": "Too many requests. Please try again later."})
-			c.Abort()
-			return
-		}
+	now := time.Now()
+	v, exists := rl.visitors[ip]
+	if !exists {
+		rl.visitors[ip] = &visitor{windowStart: now, lastSeen: now, count: 1}
+		return true
+	}
 
-		limiter.mu.Unlock()
-		c.Next()
+	// Reset count if rate period has passed
+	if now.Sub(v.windowStart) > rl.rate {
+		v.windowStart = now
+		v.lastSeen = now
+		v.count = 1
+		return true
+	}
+
+	if v.count >= rl.burst {
+		return false
+	}
+	v.count++
+	v.lastSeen = now
+	return true
+}
+
+// cleanup drops visitors that haven't been seen for a full rate period
+func (rl *rateLimiter) cleanup() {
+	ticker := time.NewTicker(rl.rate)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.mu.Lock()
+		for ip, v := range rl.visitors {
+			if time.Since(v.lastSeen) > rl.rate {
+				delete(rl.visitors, ip)
+			}
+		}
+		rl.mu.Unlock()
 	}
 }
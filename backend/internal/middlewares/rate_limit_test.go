@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBlocksAfterBurst(t *testing.T) {
+	rl := &rateLimiter{visitors: make(map[string]*visitor), rate: time.Minute, burst: 3}
+
+	for i := 1; i <= 3; i++ {
+		if !rl.allow("10.0.0.1") {
+			t.Fatalf("request %d was blocked", i)
+		}
+	}
+	if rl.allow("10.0.0.1") {
+		t.Error("a request past the burst was allowed")
+	}
+	if !rl.allow("10.0.0.2") {
+		t.Error("another IP was blocked")
+	}
+}
+
+func TestRateLimiterUnblocksWhileRetrying(t *testing.T) {
+	rl := &rateLimiter{visitors: make(map[string]*visitor), rate: 50 * time.Millisecond, burst: 1}
+
+	rl.allow("10.0.0.1")
+	deadline := time.Now().Add(rl.rate * 3)
+	for time.Now().Before(deadline) {
+		if rl.allow("10.0.0.1") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("blocked retries kept the client blocked")
+}
+
+func TestRateLimitMiddlewareSeparatesRoutes(t *testing.T) {
+	a := newRateLimiter("test-a", time.Minute, 1)
+	b := newRateLimiter("test-b", time.Minute, 1)
+	if a == b {
+		t.Fatal("two routes share a limiter")
+	}
+	if newRateLimiter("test-a", time.Minute, 1) != a {
+		t.Error("a name got a second limiter")
+	}
+
+	a.allow("10.0.0.1")
+	if !b.allow("10.0.0.1") {
+		t.Error("one route's requests used up another's")
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKey is a long-lived personal access token. Its permissions are a subset of
+// what the owning user holds; only the hash of the key is stored.
+type APIKey struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID    `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name        string       `gorm:"not null" json:"name"`
+	Prefix      string       `gorm:"not null" json:"prefix"`
+	KeyHash     string       `gorm:"uniqueIndex;not null" json:"-"`
+	LastUsedAt  *time.Time   `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	User        User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Permissions []Permission `gorm:"many2many:api_key_permissions" json:"permissions,omitempty"`
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive reports whether the key can still be used.
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
+
+// PermissionNames returns the names of the permissions the key is scoped to.
+func (k *APIKey) PermissionNames() []string {
+	names := make([]string, len(k.Permissions))
+	for i, perm := range k.Permissions {
+		names[i] = perm.Name
+	}
+	return names
+}
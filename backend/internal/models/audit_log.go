@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLog records an action together with the admin who really performed it.
+// UserID is the account the action was taken as, which differs from ActorID
+// while impersonating.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActorID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"actor_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Action    string     `gorm:"not null;index" json:"action"`
+	Method    string     `json:"method,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Status    int        `json:"status,omitempty"`
+	IP        string     `json:"ip"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+	Actor     *User      `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	User      *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CaptchaChallenge is a pending captcha. Only a hash of the normalized answer
+// is stored. UserID and Username are set when the challenge was issued by a
+// successful password check and may only complete that user's login.
+// PasswordChange is set when that login must replace an expired password
+// once every step has passed.
+type CaptchaChallenge struct {
+	ID             string     `gorm:"primaryKey" json:"id"`
+	Type           string     `gorm:"not null" json:"type"`
+	AnswerHash     string     `gorm:"not null" json:"-"`
+	Image          []byte     `json:"-"`
+	UserID         *uuid.UUID `gorm:"type:uuid" json:"-"`
+	Username       string     `json:"-"`
+	Attempts       int        `gorm:"not null;default:0" json:"-"`
+	PasswordChange bool       `gorm:"not null;default:false" json:"-"`
+	CreatedAt      time.Time  `gorm:"not null;index" json:"created_at"`
+}
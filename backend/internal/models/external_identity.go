@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider.
+type ExternalIdentity struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Issuer      string    `gorm:"not null;uniqueIndex:idx_external_identity" json:"issuer"`
+	Subject     string    `gorm:"not null;uniqueIndex:idx_external_identity" json:"subject"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ei *ExternalIdentity) BeforeCreate(tx *gorm.DB) error {
+	if ei.ID == uuid.Nil {
+		ei.ID = uuid.New()
+	}
+	return nil
+}
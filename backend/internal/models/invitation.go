@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invitation lets people register while registration is invite-only. The code
+// can be used MaxUses times and gives the new account RoleID; only its hash is
+// stored.
+type Invitation struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Prefix      string     `gorm:"not null" json:"prefix"`
+	CodeHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Note        string     `json:"note,omitempty"`
+	RoleID      uuid.UUID  `gorm:"type:uuid;not null" json:"role_id"`
+	MaxUses     int        `gorm:"not null;default:1" json:"max_uses"`
+	Uses        int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null;index" json:"created_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Role        Role       `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+}
+
+func (i *Invitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive reports whether the invitation can still be redeemed.
+func (i *Invitation) IsActive() bool {
+	if i.RevokedAt != nil || i.Uses >= i.MaxUses {
+		return false
+	}
+	return i.ExpiresAt == nil || time.Now().Before(*i.ExpiresAt)
+}
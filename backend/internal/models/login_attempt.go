@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginAttempt records every password check, successful or not. UserID is nil
+// when the username didn't match any account.
+type LoginAttempt struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Username  string     `gorm:"index" json:"username"`
+	IP        string     `gorm:"index" json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	Success   bool       `json:"success"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (la *LoginAttempt) BeforeCreate(tx *gorm.DB) error {
+	if la.ID == uuid.Nil {
+		la.ID = uuid.New()
+	}
+	return nil
+}
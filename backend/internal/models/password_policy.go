@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicy holds the rules every new password must satisfy. There is a
+// single row, created with defaults on first start and edited by admins.
+type PasswordPolicy struct {
+	ID            uint      `gorm:"primaryKey" json:"-"`
+	MinLength     int       `gorm:"not null;default:8" json:"min_length"`
+	RequireUpper  bool      `gorm:"not null;default:false" json:"require_upper"`
+	RequireLower  bool      `gorm:"not null;default:false" json:"require_lower"`
+	RequireDigit  bool      `gorm:"not null;default:false" json:"require_digit"`
+	RequireSymbol bool      `gorm:"not null;default:false" json:"require_symbol"`
+	BlockCommon   bool      `gorm:"not null;default:true" json:"block_common"`
+	HistoryCount  int       `gorm:"not null;default:0" json:"history_count"`
+	MaxAgeDays    int       `gorm:"not null;default:0" json:"max_age_days"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PasswordHistory remembers previous password hashes to prevent reuse.
+type PasswordHistory struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	User         User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ph *PasswordHistory) BeforeCreate(tx *gorm.DB) error {
+	if ph.ID == uuid.Nil {
+		ph.ID = uuid.New()
+	}
+	return nil
+}
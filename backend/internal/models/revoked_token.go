@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedToken is a denylist entry for an access token, keyed by its jti claim.
+// Entries are only needed until the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key" json:"jti"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -8,14 +8,24 @@ import (
 )
 
 type Role struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name           string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description    string       `json:"description"`
+	Rank           int          `gorm:"default:0;not null" json:"rank"`
+	ParentID       *uuid.UUID   `gorm:"type:uuid;index" json:"parent_id"`
+	Parent         *Role        `gorm:"foreignKey:ParentID;constraint:OnDelete:SET NULL" json:"parent,omitempty"`
+	Require2FA     bool         `gorm:"column:require_2fa;default:false" json:"require_2fa"`
+	AllowMagicLink bool         `gorm:"default:false" json:"allow_magic_link"`
+	BuiltIn        bool         `gorm:"default:false" json:"built_in"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Permissions    []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
 }
 
+// BuiltInRoles are the seeded roles. The code refers to them by name, so they
+// can't be renamed or deleted.
+var BuiltInRoles = []string{"admin", "manager", "viewer"}
+
 func (r *Role) BeforeCreate(tx *gorm.DB) error {
 	if r.ID == uuid.Nil {
 		r.ID = uuid.New()
@@ -4,70 +4,65 @@ import (
 	"gorm.io/gorm"
 )
 
-func SeedRolesAndPermissions(db *gorm.DB) error {
-	// Check if already seeded
-	var roleCount int64
-	db.Model(&Role{}).Count(&roleCount)
-	if roleCount > 0 {
-		return nil // Already seeded
-	}
+var builtInRoleDescriptions = map[string]string{
+	"admin":   "Full system access",
+	"manager": "User and analytics management",
+	"viewer":  "Read-only access",
+}
 
-	// Create permissions
-	permissions := []Permission{
-		{Name: "USER_CREATE", Description: "Create new users"},
-		{Name: "USER_READ", Description: "View users"},
-		{Name: "USER_UPDATE", Description: "Update users"},
-		{Name: "USER_DELETE", Description: "Delete/deactivate users"},
-		{Name: "ROLE_MANAGE", Description: "Manage roles and permissions"},
-		{Name: "ANALYTICS_VIEW", Description: "View analytics and reports"},
-		{Name: "CHAT_SEND", Description: "Send chat messages"},
-	}
+// builtInRoleRanks are the ranks the built-in roles start with; users can only
+// manage users and roles ranked below them.
+var builtInRoleRanks = map[string]int{
+	"admin":   100,
+	"manager": 50,
+	"viewer":  10,
+}
 
-	for _, perm := range permissions {
-		if err := db.FirstOrCreate(&perm, Permission{Name: perm.Name}).Error; err != nil {
-			return err
+// builtInRoleParents is the hierarchy new databases start with. Existing
+// databases keep whatever hierarchy admins have set up.
+var builtInRoleParents = map[string]string{
+	"admin":   "manager",
+	"manager": "viewer",
+}
+
+// SeedRoles creates the built-in roles if they are missing. Their permissions
+// come from the default grants in the permission registry.
+func SeedRoles(db *gorm.DB) error {
+	roles := make(map[string]*Role, len(BuiltInRoles))
+	created := make(map[string]bool, len(BuiltInRoles))
+	for _, name := range BuiltInRoles {
+		role := Role{Name: name, Description: builtInRoleDescriptions[name], Rank: builtInRoleRanks[name], BuiltIn: true}
+		result := db.FirstOrCreate(&role, Role{Name: name})
+		if result.Error != nil {
+			return result.Error
+		}
+		if !role.BuiltIn {
+			if err := db.Model(&role).Update("built_in", true).Error; err != nil {
+				return err
+			}
 		}
+		roles[name] = &role
+		created[name] = result.RowsAffected > 0
 	}
 
-	// Create roles
-	adminRole := Role{
-		Name:        "admin",
-		Description: "Full system access",
-	}
-	managerRole := Role{
-		Name:        "manager",
-		Description: "User and analytics management",
-	}
-	viewerRole := Role{
-		Name:        "viewer",
-		Description: "Read-only access",
+	for name, parentName := range builtInRoleParents {
+		if !created[name] {
+			continue
+		}
+		if err := db.Model(roles[name]).Update("parent_id", roles[parentName].ID).Error; err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := db.FirstOrCreate(&adminRole, Role{Name: "admin"}).Error; err != nil {
-		return err
-	}
-	if err := db.FirstOrCreate(&managerRole, Role{Name: "manager"}).Error; err != nil {
-		return err
-	}
-	if err := db.FirstOrCreate(&viewerRole, Role{Name: "viewer"}).Error; err != nil {
-		return err
+// RankBuiltInRoles gives the built-in roles their starting ranks, for
+// databases that predate role ranks.
+func RankBuiltInRoles(db *gorm.DB) error {
+	for name, rank := range builtInRoleRanks {
+		if err := db.Model(&Role{}).Where("name = ?", name).Update("rank", rank).Error; err != nil {
+			return err
+		}
 	}
-
-	// Assign permissions to roles
-	// Admin gets all permissions
-	var allPerms []Permission
-	db.Find(&allPerms)
-	db.Model(&adminRole).Association("Permissions").Replace(allPerms)
-
-	// Manager gets user management and analytics
-	managerPerms := []Permission{}
-	db.Where("name IN ?", []string{"USER_CREATE", "USER_READ", "USER_UPDATE", "ANALYTICS_VIEW", "CHAT_SEND"}).Find(&managerPerms)
-	db.Model(&managerRole).Association("Permissions").Replace(managerPerms)
-
-	// Viewer gets read-only
-	viewerPerms := []Permission{}
-	db.Where("name IN ?", []string{"USER_READ", "ANALYTICS_VIEW", "CHAT_SEND"}).Find(&viewerPerms)
-	db.Model(&viewerRole).Association("Permissions").Replace(viewerPerms)
-
 	return nil
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is one refresh token family. Every login creates a session, and each
+// refresh rotates RefreshTokenHash in place, keeping the old hash as a
+// RetiredRefreshToken. Presenting one of those means the token was reused, and
+// the session is revoked.
+// Device, UserAgent and IP describe the client so users can recognise their devices.
+type Session struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	RefreshTokenHash string     `gorm:"not null" json:"-"`
+	Device           string     `json:"device"`
+	UserAgent        string     `json:"user_agent"`
+	IP               string     `json:"ip"`
+	LastSeenAt       time.Time  `json:"last_seen_at"`
+	ExpiresAt        time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	User             User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive reports whether the session can still be used to authenticate.
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// RetiredRefreshToken is the hash of a refresh token a session has rotated
+// away from. Only these count as reuse; other unknown secrets are just invalid.
+type RetiredRefreshToken struct {
+	SessionID uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	TokenHash string    `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	Session   Session   `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -8,16 +8,30 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	FullName     string    `gorm:"not null" json:"full_name"`
-	Username     string    `gorm:"uniqueIndex;not null" json:"username"`
-	PasswordHash string    `gorm:"not null" json:"-"`
-	IsActive     bool      `gorm:"default:true" json:"is_active"`
-	RoleID       uuid.UUID `gorm:"type:uuid;not null" json:"role_id"`
-	Role         Role      `gorm:"foreignKey:RoleID;constraint:OnDelete:SET NULL" json:"role,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FullName      string    `gorm:"not null" json:"full_name"`
+	Username      string    `gorm:"uniqueIndex;not null" json:"username"`
+	Email         *string   `gorm:"uniqueIndex" json:"email,omitempty"`
+	EmailVerified bool      `gorm:"default:false" json:"email_verified"`
+	PasswordHash  string    `gorm:"not null" json:"-"`
+	// PasswordChangedAt is nil for accounts created before password expiry existed
+	PasswordChangedAt  *time.Time     `json:"password_changed_at,omitempty"`
+	MustChangePassword bool           `gorm:"default:false" json:"must_change_password"`
+	IsActive           bool           `gorm:"default:true" json:"is_active"`
+	TOTPSecret         string         `json:"-"`
+	TOTPEnabled        bool           `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep       int64          `gorm:"default:0" json:"-"`
+	FailedLogins       int            `gorm:"default:0" json:"failed_logins"`
+	LastFailedAt       *time.Time     `json:"last_failed_at,omitempty"`
+	LockedUntil        *time.Time     `json:"locked_until,omitempty"`
+	RoleID             uuid.UUID      `gorm:"type:uuid;not null" json:"role_id"`
+	Role               Role           `gorm:"foreignKey:RoleID;constraint:OnDelete:RESTRICT" json:"role,omitempty"`
+	Roles              []Role         `gorm:"many2many:user_roles" json:"roles,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EffectivePermissions []string `gorm:"-" json:"effective_permissions,omitempty"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
@@ -26,3 +40,8 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// AfterCreate puts the primary role (RoleID) into the user's role set.
+func (u *User) AfterCreate(tx *gorm.DB) error {
+	return tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", u.ID, u.RoleID).Error
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission override effects
+const (
+	OverrideGrant = "grant"
+	OverrideDeny  = "deny"
+)
+
+// UserPermissionOverride grants or denies one permission to one user on top
+// of what their roles give them. A deny wins over every grant.
+type UserPermissionOverride struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_user_permission_override" json:"user_id"`
+	PermissionID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_user_permission_override" json:"permission_id"`
+	Effect       string     `gorm:"not null" json:"effect"`
+	CreatedAt    time.Time  `json:"created_at"`
+	User         User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Permission   Permission `gorm:"foreignKey:PermissionID;constraint:OnDelete:CASCADE" json:"permission"`
+}
+
+func (o *UserPermissionOverride) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
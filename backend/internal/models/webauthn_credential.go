@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential is a passkey registered by a user. A user can have
+// several, one per authenticator. PublicKey is the COSE_Key from registration.
+type WebAuthnCredential struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name           string     `gorm:"not null" json:"name"`
+	CredentialID   []byte     `gorm:"uniqueIndex;not null" json:"-"`
+	PublicKey      []byte     `gorm:"not null" json:"-"`
+	Algorithm      int64      `gorm:"not null" json:"algorithm"`
+	SignCount      int64      `gorm:"not null;default:0" json:"-"`
+	AAGUID         []byte     `json:"-"`
+	Transports     string     `json:"-"` // comma-separated, passed back to the browser as hints
+	BackupEligible bool       `json:"backup_eligible"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	User           User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (w *WebAuthnCredential) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package oidc
+
+import (
+	"admin-dashboard/internal/config"
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// Default is the configured identity provider, or nil when SSO is disabled.
+var Default *Provider
+
+// Init sets up Default from config. It's a no-op when OIDC is disabled.
+func Init() error {
+	if !config.AppConfig.OIDCEnabled {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	provider, err := NewProvider(ctx, Config{
+		Issuer:       config.AppConfig.OIDCIssuer,
+		ClientID:     config.AppConfig.OIDCClientID,
+		ClientSecret: config.AppConfig.OIDCClientSecret,
+		RedirectURL:  config.AppConfig.OIDCRedirectURL,
+		Scopes:       strings.Fields(config.AppConfig.OIDCScopes),
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	Default = provider
+	log.Printf("OIDC login enabled (issuer %s)", config.AppConfig.OIDCIssuer)
+	return nil
+}
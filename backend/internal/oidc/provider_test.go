@@ -0,0 +1,432 @@
+package oidc
+
+import (
+	"admin-dashboard/internal/utils"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "dashboard"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://localhost/callback"
+)
+
+// mockIssuer is a minimal OpenID provider: discovery, JWKS, an authorization
+// endpoint that hands out codes and a token endpoint that checks PKCE.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+	// signingKey, when set, signs ID tokens instead of key, under the same kid
+	signingKey *rsa.PrivateKey
+	// claims are merged into every ID token, overriding the defaults
+	claims jwt.MapClaims
+	// reportedIssuer, when set, is the issuer claimed by the discovery document
+	reportedIssuer string
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	m := &mockIssuer{
+		key:   newRSAKey(t),
+		kid:   "key-1",
+		codes: make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.server.URL
+		if m.reportedIssuer != "" {
+			issuer = m.reportedIssuer
+		}
+		writeJSON(w, http.StatusOK, Discovery{
+			Issuer:                issuer,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		jwk, err := utils.NewJWK(&m.key.PublicKey, m.kid, "RS256")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, utils.JWKSet{Keys: []utils.JWK{jwk}})
+	})
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.codes[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	params := url.Values{"code": {code}, "state": {query.Get("state")}}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := m.idToken(grant.nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+}
+
+func (m *mockIssuer) idToken(nonce string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                m.server.URL,
+		"aud":                testClientID,
+		"sub":                "user-123",
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              "jane@example.com",
+		"email_verified":     true,
+		"name":               "Jane Doe",
+		"preferred_username": "jane",
+		"groups":             []string{"staff", "admins"},
+	}
+	for name, value := range m.claims {
+		claims[name] = value
+	}
+
+	key := m.key
+	if m.signingKey != nil {
+		key = m.signingKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.kid
+	return token.SignedString(key)
+}
+
+// login runs the authorization step like a browser would and returns the code.
+func (m *mockIssuer) login(t *testing.T, p *Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization request returned %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if got := location.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return location.Query().Get("code")
+}
+
+func (m *mockIssuer) provider(t *testing.T) *Provider {
+	t.Helper()
+
+	p, err := NewProvider(context.Background(), Config{
+		Issuer:       m.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return p
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newMockIssuer(t)
+	p := issuer.provider(t)
+
+	code := issuer.login(t, p, "state-1", "nonce-1", "verifier-1")
+	claims, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if claims.Name != "Jane Doe" || claims.PreferredUsername != "jane" {
+		t.Errorf("unexpected profile claims: %+v", claims)
+	}
+	if groups := claims.StringsClaim("groups"); len(groups) != 2 || groups[0] != "staff" || groups[1] != "admins" {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newMockIssuer(t)
+	p := issuer.provider(t)
+
+	authURL, err := url.Parse(p.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	challenge := sha256.Sum256([]byte("verifier-1"))
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(challenge[:]),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if query.Get("code_verifier") != "" {
+		t.Error("the PKCE verifier must not be sent to the authorization endpoint")
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup changes the issuer before the login
+		setup    func(t *testing.T, m *mockIssuer)
+		verifier string
+		nonce    string
+		want     string
+	}{
+		{
+			name:     "nonce mismatch",
+			verifier: "verifier-1",
+			nonce:    "someone-elses-nonce",
+			want:     "nonce mismatch",
+		},
+		{
+			name:     "PKCE verifier mismatch",
+			verifier: "wrong-verifier",
+			nonce:    "nonce-1",
+			want:     "invalid_grant",
+		},
+		{
+			name: "bad signature",
+			setup: func(t *testing.T, m *mockIssuer) {
+				m.signingKey = newRSAKey(t)
+			},
+			verifier: "verifier-1",
+			nonce:    "nonce-1",
+			want:     "signature is invalid",
+		},
+		{
+			name: "expired id_token",
+			setup: func(t *testing.T, m *mockIssuer) {
+				m.claims = jwt.MapClaims{"exp": time.Now().Add(-10 * time.Minute).Unix()}
+			},
+			verifier: "verifier-1",
+			nonce:    "nonce-1",
+			want:     "expired",
+		},
+		{
+			name: "wrong audience",
+			setup: func(t *testing.T, m *mockIssuer) {
+				m.claims = jwt.MapClaims{"aud": "another-client"}
+			},
+			verifier: "verifier-1",
+			nonce:    "nonce-1",
+			want:     "audience",
+		},
+		{
+			name: "wrong issuer",
+			setup: func(t *testing.T, m *mockIssuer) {
+				m.claims = jwt.MapClaims{"iss": "https://evil.example.com"}
+			},
+			verifier: "verifier-1",
+			nonce:    "nonce-1",
+			want:     "issuer",
+		},
+		{
+			name: "missing subject",
+			setup: func(t *testing.T, m *mockIssuer) {
+				m.claims = jwt.MapClaims{"sub": ""}
+			},
+			verifier: "verifier-1",
+			nonce:    "nonce-1",
+			want:     "missing sub",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			p := issuer.provider(t)
+			if tt.setup != nil {
+				tt.setup(t, issuer)
+			}
+
+			code := issuer.login(t, p, "state-1", "nonce-1", "verifier-1")
+			_, err := p.Exchange(context.Background(), code, tt.verifier, tt.nonce)
+			if err == nil {
+				t.Fatal("Exchange succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	issuer := newMockIssuer(t)
+	p := issuer.provider(t)
+
+	code := issuer.login(t, p, "state-1", "nonce-1", "verifier-1")
+	if _, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err == nil {
+		t.Fatal("second Exchange with the same code succeeded")
+	}
+}
+
+func TestVerifyIDTokenRejectsSymmetricAlgorithms(t *testing.T) {
+	issuer := newMockIssuer(t)
+	p := issuer.provider(t)
+
+	// An HS256 token keyed with the client secret must not pass as an identity
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   issuer.server.URL,
+		"aud":   testClientID,
+		"sub":   "attacker",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "nonce-1",
+	})
+	token.Header["kid"] = issuer.kid
+	raw, err := token.SignedString([]byte(testClientSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.VerifyIDToken(context.Background(), raw, "nonce-1"); err == nil {
+		t.Fatal("VerifyIDToken accepted an HS256 token")
+	}
+}
+
+func TestVerifyIDTokenRefetchesRotatedKeys(t *testing.T) {
+	issuer := newMockIssuer(t)
+	p := issuer.provider(t)
+
+	// The provider rotates to a new key after we cached the old one
+	issuer.mu.Lock()
+	issuer.key = newRSAKey(t)
+	issuer.kid = "key-2"
+	issuer.mu.Unlock()
+
+	raw, err := issuer.idToken("nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(context.Background(), raw, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "user-123" {
+		t.Errorf("Subject = %q", claims.Subject)
+	}
+}
+
+func TestNewProviderRejectsIssuerMismatch(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.reportedIssuer = "https://other.example.com"
+
+	_, err := NewProvider(context.Background(), Config{
+		Issuer:   issuer.server.URL,
+		ClientID: testClientID,
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("error = %v, want an issuer mismatch", err)
+	}
+}
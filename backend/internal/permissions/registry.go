@@ -0,0 +1,151 @@
+// Package permissions holds the permissions the code knows about. Packages
+// declare the permissions they check with Register, usually from init, and
+// Reconcile brings the permissions table in line with them at startup.
+package permissions
+
+import (
+	"admin-dashboard/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Definition declares a permission.
+type Definition struct {
+	Name        string
+	Description string
+	// DefaultRoles are granted the permission when it is first added to the
+	// database. Admins can revoke it afterwards; it isn't granted again.
+	DefaultRoles []string
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Definition{}
+)
+
+// Register declares permissions. Registering the same name twice is a
+// programming error and panics.
+func Register(defs ...Definition) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, def := range defs {
+		if def.Name == "" {
+			panic("permissions: empty permission name")
+		}
+		if _, exists := registry[def.Name]; exists {
+			panic(fmt.Sprintf("permissions: %s registered twice", def.Name))
+		}
+		registry[def.Name] = def
+	}
+}
+
+// All returns the registered permissions sorted by name.
+func All() []Definition {
+	mu.RLock()
+	defer mu.RUnlock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// IsRegistered reports whether name was registered.
+func IsRegistered(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// MustBeRegistered panics if name wasn't registered. Call it where permission
+// names are wired up, so a typo fails at startup instead of denying everyone.
+func MustBeRegistered(name string) {
+	if !IsRegistered(name) {
+		panic(fmt.Sprintf("permissions: %s is not registered", name))
+	}
+}
+
+// Reconcile inserts registered permissions missing from the database, granting
+// them to their default roles, and updates changed descriptions. Permissions in
+// the database that nothing registers any more are flagged as orphaned and
+// logged, never deleted: roles and API keys may still refer to them.
+func Reconcile(db *gorm.DB) error {
+	defs := All()
+	if len(defs) == 0 {
+		// Flagging every permission as orphaned would be wrong; the caller
+		// just didn't import the packages that register them
+		return errors.New("no permissions registered")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Permission
+		if err := tx.Find(&existing).Error; err != nil {
+			return err
+		}
+		byName := make(map[string]models.Permission, len(existing))
+		for _, perm := range existing {
+			byName[perm.Name] = perm
+		}
+
+		for _, def := range defs {
+			perm, ok := byName[def.Name]
+			if !ok {
+				if err := addPermission(tx, def); err != nil {
+					return fmt.Errorf("failed to add permission %s: %w", def.Name, err)
+				}
+				continue
+			}
+
+			if perm.Description != def.Description || perm.Orphaned {
+				if err := tx.Model(&perm).Updates(map[string]interface{}{
+					"description": def.Description,
+					"orphaned":    false,
+				}).Error; err != nil {
+					return fmt.Errorf("failed to update permission %s: %w", def.Name, err)
+				}
+			}
+		}
+
+		for _, perm := range existing {
+			if IsRegistered(perm.Name) {
+				continue
+			}
+			log.Printf("WARNING: permission %s is in the database but no longer used by the code", perm.Name)
+			if !perm.Orphaned {
+				if err := tx.Model(&perm).Update("orphaned", true).Error; err != nil {
+					return fmt.Errorf("failed to flag permission %s: %w", perm.Name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func addPermission(tx *gorm.DB, def Definition) error {
+	perm := models.Permission{Name: def.Name, Description: def.Description}
+	if err := tx.Create(&perm).Error; err != nil {
+		return err
+	}
+
+	var roles []models.Role
+	if len(def.DefaultRoles) > 0 {
+		if err := tx.Where("name IN ?", def.DefaultRoles).Find(&roles).Error; err != nil {
+			return err
+		}
+	}
+	for _, role := range roles {
+		if err := tx.Model(&role).Association("Permissions").Append(&perm); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Added permission %s (granted to %v)", def.Name, def.DefaultRoles)
+	return nil
+}
@@ -0,0 +1,188 @@
+// Package policy decides whether an actor may perform an action on a user or
+// role, on top of the plain permission checks in RequirePermission. Rules are
+// pure functions of a Request, so they can be evaluated, listed and dry-run
+// without touching the database; the services package builds the requests.
+package policy
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Actions
+const (
+	ActionUserCreate = "user.create"
+	ActionUserUpdate = "user.update"
+	ActionUserDelete = "user.delete"
+	ActionRoleCreate = "role.create"
+	ActionRoleUpdate = "role.update"
+	ActionRoleDelete = "role.delete"
+)
+
+// Actions lists every action, for validating input.
+var Actions = []string{
+	ActionUserCreate, ActionUserUpdate, ActionUserDelete,
+	ActionRoleCreate, ActionRoleUpdate, ActionRoleDelete,
+}
+
+// Subject describes a user taking part in a request.
+type Subject struct {
+	ID uuid.UUID `json:"id"`
+	// Rank is the highest rank among the user's roles
+	Rank        int      `json:"rank"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleSubject describes a role being acted on.
+type RoleSubject struct {
+	ID   uuid.UUID `json:"id"`
+	Rank int       `json:"rank"`
+}
+
+// Request is one authorization question: may Actor perform Action on the
+// target, with the given effect?
+type Request struct {
+	Action string  `json:"action"`
+	Actor  Subject `json:"actor"`
+	// TargetUser is the existing user acted on, if any
+	TargetUser *Subject `json:"target_user,omitempty"`
+	// TargetRole is the existing role acted on, if any
+	TargetRole *RoleSubject `json:"target_role,omitempty"`
+	// GrantedRanks are the ranks the action hands out: roles given to a
+	// user, or the new rank of a role
+	GrantedRanks []int `json:"granted_ranks,omitempty"`
+	// GrantedPermissions are the permissions the action adds to a user or role
+	GrantedPermissions []string `json:"granted_permissions,omitempty"`
+}
+
+// Rule is one policy. Check returns a reason when it denies the request.
+type Rule struct {
+	Name        string
+	Description string
+	// Actions the rule applies to; empty means every action
+	Actions []string
+	Check   func(Request) (denied bool, reason string)
+}
+
+func (r Rule) appliesTo(action string) bool {
+	if len(r.Actions) == 0 {
+		return true
+	}
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleResult is the outcome of one rule.
+type RuleResult struct {
+	Rule    string `json:"rule"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Decision is the outcome of evaluating a request. The request is allowed
+// only if every rule that applies allows it.
+type Decision struct {
+	Allowed bool `json:"allowed"`
+	// DeniedBy and Reason come from the first rule that denied the request
+	DeniedBy string       `json:"denied_by,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Results  []RuleResult `json:"results"`
+}
+
+// Engine evaluates requests against a fixed set of rules.
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Rules returns the engine's rules in evaluation order.
+func (e *Engine) Rules() []Rule {
+	return append([]Rule(nil), e.rules...)
+}
+
+// Evaluate runs every applicable rule, so dry runs can show all of the
+// reasons a request would be denied.
+func (e *Engine) Evaluate(req Request) Decision {
+	decision := Decision{Allowed: true, Results: []RuleResult{}}
+	for _, rule := range e.rules {
+		if !rule.appliesTo(req.Action) {
+			continue
+		}
+		denied, reason := rule.Check(req)
+		decision.Results = append(decision.Results, RuleResult{Rule: rule.Name, Allowed: !denied, Reason: reason})
+		if denied && decision.Allowed {
+			decision.Allowed = false
+			decision.DeniedBy = rule.Name
+			decision.Reason = reason
+		}
+	}
+	return decision
+}
+
+// Default is the engine the handlers enforce.
+var Default = NewEngine(DefaultRules...)
+
+// DefaultRules are the rules of the Default engine.
+var DefaultRules = []Rule{
+	{
+		Name:        "not-self",
+		Description: "Users may not update or deactivate their own account through user management",
+		Actions:     []string{ActionUserUpdate, ActionUserDelete},
+		Check: func(req Request) (bool, string) {
+			if req.TargetUser != nil && req.TargetUser.ID == req.Actor.ID {
+				return true, "you may not modify your own account here"
+			}
+			return false, ""
+		},
+	},
+	{
+		Name:        "target-rank-below-actor",
+		Description: "Users may only manage users and roles ranked below their own rank",
+		Actions:     []string{ActionUserUpdate, ActionUserDelete, ActionRoleUpdate, ActionRoleDelete},
+		Check: func(req Request) (bool, string) {
+			if req.TargetUser != nil && req.TargetUser.Rank >= req.Actor.Rank {
+				return true, fmt.Sprintf("the user's rank (%d) is not below yours (%d)", req.TargetUser.Rank, req.Actor.Rank)
+			}
+			if req.TargetRole != nil && req.TargetRole.Rank >= req.Actor.Rank {
+				return true, fmt.Sprintf("the role's rank (%d) is not below yours (%d)", req.TargetRole.Rank, req.Actor.Rank)
+			}
+			return false, ""
+		},
+	},
+	{
+		Name:        "granted-rank-below-actor",
+		Description: "Users may only hand out roles, or set role ranks, below their own rank",
+		Check: func(req Request) (bool, string) {
+			for _, rank := range req.GrantedRanks {
+				if rank >= req.Actor.Rank {
+					return true, fmt.Sprintf("rank %d is not below yours (%d)", rank, req.Actor.Rank)
+				}
+			}
+			return false, ""
+		},
+	},
+	{
+		Name:        "no-privilege-escalation",
+		Description: "Users may only hand out permissions they have themselves",
+		Check: func(req Request) (bool, string) {
+			held := make(map[string]bool, len(req.Actor.Permissions))
+			for _, perm := range req.Actor.Permissions {
+				held[perm] = true
+			}
+			for _, perm := range req.GrantedPermissions {
+				if !held[perm] {
+					return true, fmt.Sprintf("you don't have the %s permission", perm)
+				}
+			}
+			return false, ""
+		},
+	},
+}
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	actorID  = uuid.New()
+	targetID = uuid.New()
+)
+
+// manager is the actor in most cases: rank 50 with a couple of permissions.
+func manager() Subject {
+	return Subject{ID: actorID, Rank: 50, Permissions: []string{"USER_READ", "USER_UPDATE"}}
+}
+
+func TestDefaultRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Request
+		deniedBy string
+	}{
+		// not-self
+		{
+			name:     "updating yourself",
+			req:      Request{Action: ActionUserUpdate, Actor: manager(), TargetUser: &Subject{ID: actorID, Rank: 50}},
+			deniedBy: "not-self",
+		},
+		{
+			name:     "deleting yourself",
+			req:      Request{Action: ActionUserDelete, Actor: manager(), TargetUser: &Subject{ID: actorID, Rank: 50}},
+			deniedBy: "not-self",
+		},
+		{
+			name: "updating someone else",
+			req:  Request{Action: ActionUserUpdate, Actor: manager(), TargetUser: &Subject{ID: targetID, Rank: 10}},
+		},
+
+		// target-rank-below-actor
+		{
+			name:     "updating a user of equal rank",
+			req:      Request{Action: ActionUserUpdate, Actor: manager(), TargetUser: &Subject{ID: targetID, Rank: 50}},
+			deniedBy: "target-rank-below-actor",
+		},
+		{
+			name:     "deleting a user of higher rank",
+			req:      Request{Action: ActionUserDelete, Actor: manager(), TargetUser: &Subject{ID: targetID, Rank: 100}},
+			deniedBy: "target-rank-below-actor",
+		},
+		{
+			name:     "updating a role of equal rank",
+			req:      Request{Action: ActionRoleUpdate, Actor: manager(), TargetRole: &RoleSubject{ID: targetID, Rank: 50}},
+			deniedBy: "target-rank-below-actor",
+		},
+		{
+			name:     "deleting a role of higher rank",
+			req:      Request{Action: ActionRoleDelete, Actor: manager(), TargetRole: &RoleSubject{ID: targetID, Rank: 60}},
+			deniedBy: "target-rank-below-actor",
+		},
+		{
+			name: "updating a role ranked below",
+			req:  Request{Action: ActionRoleUpdate, Actor: manager(), TargetRole: &RoleSubject{ID: targetID, Rank: 49}},
+		},
+
+		// granted-rank-below-actor
+		{
+			name:     "creating a user with a role of equal rank",
+			req:      Request{Action: ActionUserCreate, Actor: manager(), GrantedRanks: []int{50}},
+			deniedBy: "granted-rank-below-actor",
+		},
+		{
+			name:     "giving a role ranked above",
+			req:      Request{Action: ActionUserUpdate, Actor: manager(), TargetUser: &Subject{ID: targetID, Rank: 10}, GrantedRanks: []int{10, 100}},
+			deniedBy: "granted-rank-below-actor",
+		},
+		{
+			name:     "creating a role at your own rank",
+			req:      Request{Action: ActionRoleCreate, Actor: manager(), GrantedRanks: []int{50}},
+			deniedBy: "granted-rank-below-actor",
+		},
+		{
+			name: "creating a user with a role ranked below",
+			req:  Request{Action: ActionUserCreate, Actor: manager(), GrantedRanks: []int{10}},
+		},
+
+		// no-privilege-escalation
+		{
+			name:     "granting a permission you don't have",
+			req:      Request{Action: ActionUserCreate, Actor: manager(), GrantedPermissions: []string{"USER_READ", "ROLE_MANAGE"}},
+			deniedBy: "no-privilege-escalation",
+		},
+		{
+			name:     "adding a permission you don't have to a role",
+			req:      Request{Action: ActionRoleUpdate, Actor: manager(), TargetRole: &RoleSubject{ID: targetID, Rank: 10}, GrantedPermissions: []string{"USER_DELETE"}},
+			deniedBy: "no-privilege-escalation",
+		},
+		{
+			name: "granting permissions you have",
+			req:  Request{Action: ActionRoleCreate, Actor: manager(), GrantedRanks: []int{0}, GrantedPermissions: []string{"USER_READ", "USER_UPDATE"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := Default.Evaluate(tt.req)
+			if tt.deniedBy == "" {
+				if !decision.Allowed {
+					t.Fatalf("denied by %s (%s), want allowed", decision.DeniedBy, decision.Reason)
+				}
+				return
+			}
+			if decision.Allowed {
+				t.Fatalf("allowed, want denied by %s", tt.deniedBy)
+			}
+			if decision.DeniedBy != tt.deniedBy {
+				t.Errorf("denied by %s, want %s", decision.DeniedBy, tt.deniedBy)
+			}
+			if decision.Reason == "" {
+				t.Error("denied without a reason")
+			}
+		})
+	}
+}
+
+func TestEvaluateReportsEveryRule(t *testing.T) {
+	// Wrong on every count: yourself, at your rank, handing out a higher role
+	// and a permission you don't have
+	req := Request{
+		Action:             ActionUserUpdate,
+		Actor:              manager(),
+		TargetUser:         &Subject{ID: actorID, Rank: 50},
+		GrantedRanks:       []int{100},
+		GrantedPermissions: []string{"ROLE_MANAGE"},
+	}
+	decision := Default.Evaluate(req)
+
+	if decision.Allowed {
+		t.Fatal("allowed, want denied")
+	}
+	// The first rule in order is the one reported
+	if decision.DeniedBy != "not-self" {
+		t.Errorf("DeniedBy = %s, want not-self", decision.DeniedBy)
+	}
+
+	var denied []string
+	for _, result := range decision.Results {
+		if result.Allowed || result.Reason == "" {
+			t.Errorf("result %+v, want denied with a reason", result)
+		}
+		denied = append(denied, result.Rule)
+	}
+	want := []string{"not-self", "target-rank-below-actor", "granted-rank-below-actor", "no-privilege-escalation"}
+	if !reflect.DeepEqual(denied, want) {
+		t.Errorf("denying rules = %v, want %v", denied, want)
+	}
+}
+
+func TestEvaluateSkipsRulesForOtherActions(t *testing.T) {
+	decision := Default.Evaluate(Request{Action: ActionUserCreate, Actor: manager()})
+	if !decision.Allowed {
+		t.Fatalf("denied by %s, want allowed", decision.DeniedBy)
+	}
+
+	var ran []string
+	for _, result := range decision.Results {
+		ran = append(ran, result.Rule)
+	}
+	// not-self and target-rank-below-actor only apply to existing targets
+	want := []string{"granted-rank-below-actor", "no-privilege-escalation"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("rules run = %v, want %v", ran, want)
+	}
+}
+
+func TestEngineKeepsFirstDenial(t *testing.T) {
+	deny := func(reason string) func(Request) (bool, string) {
+		return func(Request) (bool, string) { return true, reason }
+	}
+	engine := NewEngine(
+		Rule{Name: "allow", Check: func(Request) (bool, string) { return false, "" }},
+		Rule{Name: "first", Check: deny("first reason")},
+		Rule{Name: "roles-only", Actions: []string{ActionRoleDelete}, Check: deny("never for users")},
+		Rule{Name: "second", Check: deny("second reason")},
+	)
+
+	decision := engine.Evaluate(Request{Action: ActionUserDelete})
+	if decision.Allowed || decision.DeniedBy != "first" || decision.Reason != "first reason" {
+		t.Errorf("decision = %+v, want denied by first", decision)
+	}
+	if len(decision.Results) != 3 {
+		t.Errorf("%d results, want 3 (roles-only doesn't apply)", len(decision.Results))
+	}
+
+	if rules := engine.Rules(); len(rules) != 4 || rules[0].Name != "allow" {
+		t.Errorf("Rules() = %v, want the four rules in order", rules)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than a JWT.
+const APIKeyPrefix = "adk_"
+
+var (
+	ErrInvalidAPIKey   = errors.New("invalid or expired API key")
+	ErrAPIKeyNotFound  = errors.New("API key not found")
+	ErrScopeNotHeld    = errors.New("API key scope exceeds the user's permissions")
+	ErrUnknownScope    = errors.New("unknown permission in API key scope")
+	ErrEmptyAPIKeyName = errors.New("API key name is required")
+)
+
+// IsAPIKey reports whether a bearer credential looks like an API key.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// CreateAPIKey creates a key scoped to the given permissions, all of which the
+// user must currently hold. The plain key is returned once and never stored.
+func CreateAPIKey(db *gorm.DB, user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, ErrEmptyAPIKeyName
+	}
+
+	held, err := utils.GetUserPermissions(db, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	heldSet := make(map[string]bool, len(held))
+	for _, perm := range held {
+		heldSet[perm] = true
+	}
+	for _, scope := range scopes {
+		if !heldSet[scope] {
+			return "", nil, ErrScopeNotHeld
+		}
+	}
+
+	var permissions []models.Permission
+	if len(scopes) > 0 {
+		if err := db.Where("name IN ?", scopes).Find(&permissions).Error; err != nil {
+			return "", nil, err
+		}
+	}
+	if len(permissions) != len(uniqueStrings(scopes)) {
+		return "", nil, ErrUnknownScope
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	key := APIKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		UserID:      user.ID,
+		Name:        name,
+		Prefix:      key[:len(APIKeyPrefix)+8],
+		KeyHash:     utils.HashToken(key),
+		ExpiresAt:   expiresAt,
+		Permissions: permissions,
+	}
+	if err := db.Create(&apiKey).Error; err != nil {
+		return "", nil, err
+	}
+	return key, &apiKey, nil
+}
+
+// AuthenticateAPIKey looks up an active key and records that it was used.
+func AuthenticateAPIKey(db *gorm.DB, key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := db.Preload("Permissions").Where("key_hash = ?", utils.HashToken(key)).First(&apiKey).Error; err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if !apiKey.IsActive() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	db.Model(&apiKey).UpdateColumn("last_used_at", now)
+	apiKey.LastUsedAt = &now
+	return &apiKey, nil
+}
+
+// ListAPIKeys returns all keys of a user, newest first.
+func ListAPIKeys(db *gorm.DB, userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := db.Preload("Permissions").Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey revokes one of the user's keys.
+func RevokeAPIKey(db *gorm.DB, userID, keyID uuid.UUID) error {
+	result := db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
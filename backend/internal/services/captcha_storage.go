@@ -0,0 +1,171 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CaptchaStorage keeps pending captcha challenges for CaptchaStore. Expiry
+// rules live in CaptchaStore; storages only need to store and delete.
+type CaptchaStorage interface {
+	Save(challenge *models.CaptchaChallenge) error
+	// Get returns ErrCaptchaNotFound if the challenge doesn't exist.
+	Get(id string) (*models.CaptchaChallenge, error)
+	// Consume deletes the challenge and returns it. When called concurrently,
+	// only one caller gets the challenge; the others get ErrCaptchaNotFound.
+	Consume(id string) (*models.CaptchaChallenge, error)
+	// UseAttempt atomically counts an answer and returns the challenge with
+	// the new count. Once maxAttempts answers were counted it returns
+	// ErrCaptchaAttemptsUsed instead, so concurrent answers can't go over.
+	UseAttempt(id string, maxAttempts int) (*models.CaptchaChallenge, error)
+	Delete(id string) error
+	DeleteCreatedBefore(cutoff time.Time) error
+}
+
+// MemoryCaptchaStorage keeps challenges in process memory. It only works
+// when a single instance serves all login requests.
+type MemoryCaptchaStorage struct {
+	challenges map[string]models.CaptchaChallenge
+	mu         sync.Mutex
+}
+
+func NewMemoryCaptchaStorage() *MemoryCaptchaStorage {
+	return &MemoryCaptchaStorage{
+		challenges: make(map[string]models.CaptchaChallenge),
+	}
+}
+
+func (s *MemoryCaptchaStorage) Save(challenge *models.CaptchaChallenge) error {
+	s.mu.Lock()
+	s.challenges[challenge.ID] = *challenge
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryCaptchaStorage) Get(id string) (*models.CaptchaChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[id]
+	if !exists {
+		return nil, ErrCaptchaNotFound
+	}
+	return &challenge, nil
+}
+
+func (s *MemoryCaptchaStorage) Consume(id string) (*models.CaptchaChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[id]
+	if !exists {
+		return nil, ErrCaptchaNotFound
+	}
+	delete(s.challenges, id)
+	return &challenge, nil
+}
+
+func (s *MemoryCaptchaStorage) UseAttempt(id string, maxAttempts int) (*models.CaptchaChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[id]
+	if !exists {
+		return nil, ErrCaptchaNotFound
+	}
+	if challenge.Attempts >= maxAttempts {
+		return nil, ErrCaptchaAttemptsUsed
+	}
+	challenge.Attempts++
+	s.challenges[id] = challenge
+	return &challenge, nil
+}
+
+func (s *MemoryCaptchaStorage) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.challenges, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryCaptchaStorage) DeleteCreatedBefore(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, challenge := range s.challenges {
+		if challenge.CreatedAt.Before(cutoff) {
+			delete(s.challenges, id)
+		}
+	}
+	return nil
+}
+
+// PostgresCaptchaStorage keeps challenges in the captcha_challenges table so
+// every instance behind a load balancer sees the same ones.
+type PostgresCaptchaStorage struct {
+	db *gorm.DB
+}
+
+func NewPostgresCaptchaStorage(db *gorm.DB) *PostgresCaptchaStorage {
+	return &PostgresCaptchaStorage{db: db}
+}
+
+func (s *PostgresCaptchaStorage) Save(challenge *models.CaptchaChallenge) error {
+	return s.db.Create(challenge).Error
+}
+
+func (s *PostgresCaptchaStorage) Get(id string) (*models.CaptchaChallenge, error) {
+	var challenge models.CaptchaChallenge
+	if err := s.db.Where("id = ?", id).First(&challenge).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCaptchaNotFound
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (s *PostgresCaptchaStorage) Consume(id string) (*models.CaptchaChallenge, error) {
+	// DELETE ... RETURNING lets exactly one concurrent caller win the row
+	var challenge models.CaptchaChallenge
+	result := s.db.Clauses(clause.Returning{}).Where("id = ?", id).Delete(&challenge)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCaptchaNotFound
+	}
+	return &challenge, nil
+}
+
+func (s *PostgresCaptchaStorage) UseAttempt(id string, maxAttempts int) (*models.CaptchaChallenge, error) {
+	// The condition and the increment are one statement, so the row lock
+	// serializes concurrent answers
+	var challenge models.CaptchaChallenge
+	result := s.db.Model(&challenge).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := s.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrCaptchaAttemptsUsed
+	}
+	return &challenge, nil
+}
+
+func (s *PostgresCaptchaStorage) Delete(id string) error {
+	return s.db.Where("id = ?", id).Delete(&models.CaptchaChallenge{}).Error
+}
+
+func (s *PostgresCaptchaStorage) DeleteCreatedBefore(cutoff time.Time) error {
+	return s.db.Where("created_at < ?", cutoff).Delete(&models.CaptchaChallenge{}).Error
+}
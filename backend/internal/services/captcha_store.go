@@ -1,22 +1,39 @@
 package services
 
 import (
+	"admin-dashboard/internal/models"
 	"admin-dashboard/internal/utils"
+	"crypto/subtle"
+	"errors"
+	"log"
 	"strings"
-	"sync"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCaptchaNotFound     = errors.New("captcha not found or expired")
+	ErrCaptchaWrongAnswer  = errors.New("wrong captcha answer")
+	ErrCaptchaAttemptsUsed = errors.New("too many wrong captcha answers")
 )
 
+// CaptchaStore issues and verifies captcha challenges. Challenges issued by
+// Login are bound to the user whose password was just checked; unbound ones
+// can't be used to finish a login. Where they are kept depends on the storage.
 type CaptchaStore struct {
-	challenges map[string]utils.CaptchaChallenge
-	mu         sync.RWMutex
-	expiry     time.Duration
+	storage     CaptchaStorage
+	provider    utils.CaptchaProvider
+	expiry      time.Duration
+	maxAttempts int
 }
 
-func NewCaptchaStore() *CaptchaStore {
+func NewCaptchaStore(provider utils.CaptchaProvider, storage CaptchaStorage) *CaptchaStore {
 	store := &CaptchaStore{
-		challenges: make(map[string]utils.CaptchaChallenge),
-		expiry:     5 * time.Minute,
+		storage:     storage,
+		provider:    provider,
+		expiry:      5 * time.Minute,
+		maxAttempts: 3,
 	}
 
 	// Cleanup expired captchas periodically
@@ -25,42 +42,100 @@ func NewCaptchaStore() *CaptchaStore {
 	return store
 }
 
-func (cs *CaptchaStore) Generate() utils.CaptchaChallenge {
-	challenge := utils.GenerateMathCaptcha()
-	cs.mu.Lock()
-	cs.challenges[challenge.ID] = challenge
-	cs.mu.Unlock()
-	return challenge
+// Preview returns a challenge of the configured type without storing it. Only
+// challenges from GenerateFor can finish a login, so there is nothing to keep.
+func (cs *CaptchaStore) Preview() utils.CaptchaChallenge {
+	return cs.provider.Generate()
 }
 
-func (cs *CaptchaStore) Verify(captchaID, answer string) bool {
-	if captchaID == "" || answer == "" {
-		return false
+// GenerateFor creates a challenge that only completes the login of the given
+// user, after their credentials were verified. passwordChange carries over to
+// the verified challenge, for logins that must replace an expired password.
+func (cs *CaptchaStore) GenerateFor(userID uuid.UUID, username string, passwordChange bool) (utils.CaptchaChallenge, error) {
+	challenge := cs.provider.Generate()
+
+	stored := models.CaptchaChallenge{
+		ID:             challenge.ID,
+		Type:           challenge.Type,
+		AnswerHash:     hashCaptchaAnswer(challenge.Answer),
+		Image:          challenge.Image,
+		Username:       username,
+		CreatedAt:      time.Now(),
+		PasswordChange: passwordChange,
+	}
+	if userID != uuid.Nil {
+		stored.UserID = &userID
 	}
 
-	cs.mu.RLock()
-	challenge, exists := cs.challenges[captchaID]
-	cs.mu.RUnlock()
+	if err := cs.storage.Save(&stored); err != nil {
+		return utils.CaptchaChallenge{}, err
+	}
+	return challenge, nil
+}
 
-	if !exists {
-		return false
+// Image returns the PNG of an image captcha without consuming the challenge.
+func (cs *CaptchaStore) Image(captchaID string) ([]byte, bool) {
+	challenge, err := cs.lookup(captchaID)
+	if err != nil || len(challenge.Image) == 0 {
+		return nil, false
 	}
+	return challenge.Image, true
+}
 
-	// Normalize answer comparison (trim whitespace, case-insensitive for string captchas)
-	normalizedAnswer := strings.TrimSpace(answer)
-	normalizedStored := strings.TrimSpace(challenge.Answer)
+// Verify checks the answer of a challenge bound to username. Every answer uses
+// up an attempt before it is compared, so concurrent answers can't get past the
+// limit. A correct answer consumes the challenge and returns it; the challenge
+// is dropped once no attempts are left. Presenting a challenge for a different
+// username also drops it.
+func (cs *CaptchaStore) Verify(captchaID, username, answer string) (*models.CaptchaChallenge, error) {
+	if captchaID == "" || answer == "" {
+		return nil, ErrCaptchaNotFound
+	}
 
-	// Verify answer (case-sensitive for math, but allow whitespace trimming)
-	if normalizedStored != normalizedAnswer {
-		return false
+	challenge, err := cs.storage.UseAttempt(captchaID, cs.maxAttempts)
+	if err != nil {
+		if err == ErrCaptchaAttemptsUsed {
+			cs.storage.Delete(captchaID)
+		}
+		return nil, err
+	}
+	if cs.expired(challenge) {
+		cs.storage.Delete(captchaID)
+		return nil, ErrCaptchaNotFound
 	}
 
-	// Remove used captcha
-	cs.mu.Lock()
-	delete(cs.challenges, captchaID)
-	cs.mu.Unlock()
+	if challenge.UserID == nil || challenge.Username != username {
+		cs.storage.Delete(captchaID)
+		return nil, ErrCaptchaNotFound
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashCaptchaAnswer(answer)), []byte(challenge.AnswerHash)) != 1 {
+		if challenge.Attempts >= cs.maxAttempts {
+			cs.storage.Delete(captchaID)
+			return nil, ErrCaptchaAttemptsUsed
+		}
+		return nil, ErrCaptchaWrongAnswer
+	}
+
+	// Remove used captcha; if another request got there first, this one loses
+	return cs.storage.Consume(captchaID)
+}
 
-	return true
+// lookup returns a live challenge, dropping it if it has expired.
+func (cs *CaptchaStore) lookup(captchaID string) (*models.CaptchaChallenge, error) {
+	challenge, err := cs.storage.Get(captchaID)
+	if err != nil {
+		return nil, err
+	}
+	if cs.expired(challenge) {
+		cs.storage.Delete(captchaID)
+		return nil, ErrCaptchaNotFound
+	}
+	return challenge, nil
+}
+
+func (cs *CaptchaStore) expired(challenge *models.CaptchaChallenge) bool {
+	return time.Since(challenge.CreatedAt) > cs.expiry
 }
 
 func (cs *CaptchaStore) cleanup() {
@@ -68,17 +143,14 @@ func (cs *CaptchaStore) cleanup() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		cs.mu.Lock()
-		now := time.Now()
-		for id, challenge := range cs.challenges {
-			// Note: We don't track creation time in CaptchaChallenge
-			// For simplicity, we'll just limit the total number of stored captchas
-			if len(cs.challenges) > 1000 {
-				delete(cs.challenges, id)
-			}
-			_ = challenge
-			_ = now
+		if err := cs.storage.DeleteCreatedBefore(time.Now().Add(-cs.expiry)); err != nil {
+			log.Printf("Failed to clean up expired captchas: %v", err)
 		}
-		cs.mu.Unlock()
 	}
 }
+
+// hashCaptchaAnswer normalizes an answer (letter codes are case-insensitive,
+// math answers are digits either way) and hashes it for storage.
+func hashCaptchaAnswer(answer string) string {
+	return utils.HashToken(strings.ToUpper(strings.TrimSpace(answer)))
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fixedCaptchaProvider issues challenges whose answer is always 42.
+type fixedCaptchaProvider struct{}
+
+func (fixedCaptchaProvider) Name() string { return utils.CaptchaTypeMath }
+
+func (fixedCaptchaProvider) Generate() utils.CaptchaChallenge {
+	return utils.CaptchaChallenge{ID: uuid.NewString(), Type: utils.CaptchaTypeMath, Question: "40 + 2", Answer: "42"}
+}
+
+// captchaStorages returns the storages to run a test against; Postgres only
+// with a test database.
+func captchaStorages() map[string]func(t *testing.T) CaptchaStorage {
+	return map[string]func(t *testing.T) CaptchaStorage{
+		"memory":   func(t *testing.T) CaptchaStorage { return NewMemoryCaptchaStorage() },
+		"postgres": func(t *testing.T) CaptchaStorage { return NewPostgresCaptchaStorage(testDB(t)) },
+	}
+}
+
+func TestCaptchaVerify(t *testing.T) {
+	for name, newStorage := range captchaStorages() {
+		t.Run(name, func(t *testing.T) {
+			store := NewCaptchaStore(fixedCaptchaProvider{}, newStorage(t))
+			userID := uuid.New()
+
+			challenge, err := store.GenerateFor(userID, "jane", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Verify(challenge.ID, "jane", "41"); err != ErrCaptchaWrongAnswer {
+				t.Errorf("wrong answer = %v, want ErrCaptchaWrongAnswer", err)
+			}
+
+			verified, err := store.Verify(challenge.ID, "jane", " 42 ")
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if verified.UserID == nil || *verified.UserID != userID || !verified.PasswordChange {
+				t.Errorf("verified challenge = %+v", verified)
+			}
+			if _, err := store.Verify(challenge.ID, "jane", "42"); err != ErrCaptchaNotFound {
+				t.Errorf("second answer = %v, want ErrCaptchaNotFound", err)
+			}
+		})
+	}
+}
+
+func TestCaptchaVerifyRejectsOtherUsername(t *testing.T) {
+	store := NewCaptchaStore(fixedCaptchaProvider{}, NewMemoryCaptchaStorage())
+
+	challenge, err := store.GenerateFor(uuid.New(), "jane", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Verify(challenge.ID, "john", "42"); err != ErrCaptchaNotFound {
+		t.Errorf("Verify for another username = %v, want ErrCaptchaNotFound", err)
+	}
+	if _, err := store.Verify(challenge.ID, "jane", "42"); err != ErrCaptchaNotFound {
+		t.Errorf("Verify after the challenge was dropped = %v, want ErrCaptchaNotFound", err)
+	}
+
+	// Challenges not issued by a password check can't finish a login
+	unbound, err := store.GenerateFor(uuid.Nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Verify(unbound.ID, "", "42"); err != ErrCaptchaNotFound {
+		t.Errorf("Verify of an unbound challenge = %v, want ErrCaptchaNotFound", err)
+	}
+}
+
+func TestCaptchaVerifyLimitsAttempts(t *testing.T) {
+	for name, newStorage := range captchaStorages() {
+		t.Run(name, func(t *testing.T) {
+			store := NewCaptchaStore(fixedCaptchaProvider{}, newStorage(t))
+
+			challenge, err := store.GenerateFor(uuid.New(), "jane", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i < store.maxAttempts; i++ {
+				if _, err := store.Verify(challenge.ID, "jane", "0"); err != ErrCaptchaWrongAnswer {
+					t.Fatalf("wrong answer %d = %v, want ErrCaptchaWrongAnswer", i, err)
+				}
+			}
+			if _, err := store.Verify(challenge.ID, "jane", "0"); err != ErrCaptchaAttemptsUsed {
+				t.Fatalf("last wrong answer = %v, want ErrCaptchaAttemptsUsed", err)
+			}
+			if _, err := store.Verify(challenge.ID, "jane", "42"); err != ErrCaptchaNotFound {
+				t.Errorf("right answer after the limit = %v, want ErrCaptchaNotFound", err)
+			}
+		})
+	}
+}
+
+// countingCaptchaStorage counts the answers that got an attempt, and so were
+// compared.
+type countingCaptchaStorage struct {
+	CaptchaStorage
+	compared atomic.Int32
+}
+
+func (s *countingCaptchaStorage) UseAttempt(id string, maxAttempts int) (*models.CaptchaChallenge, error) {
+	challenge, err := s.CaptchaStorage.UseAttempt(id, maxAttempts)
+	if err == nil {
+		s.compared.Add(1)
+	}
+	return challenge, err
+}
+
+func TestCaptchaVerifyLimitsConcurrentAttempts(t *testing.T) {
+	storage := &countingCaptchaStorage{CaptchaStorage: NewMemoryCaptchaStorage()}
+	store := NewCaptchaStore(fixedCaptchaProvider{}, storage)
+
+	challenge, err := store.GenerateFor(uuid.New(), "jane", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Guesses sent at once
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Verify(challenge.ID, "jane", strconv.Itoa(i+100))
+		}(i)
+	}
+	wg.Wait()
+
+	if compared := int(storage.compared.Load()); compared > store.maxAttempts {
+		t.Errorf("%d guesses were compared, want at most %d", compared, store.maxAttempts)
+	}
+	if _, err := store.Verify(challenge.ID, "jane", "42"); err != ErrCaptchaNotFound {
+		t.Errorf("right answer after the burst = %v, want ErrCaptchaNotFound", err)
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"os"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBConn *gorm.DB
+	testDBErr  error
+)
+
+// testDB returns a transaction on the database in TEST_DATABASE_URL that is
+// rolled back when the test ends. Tests that need it are skipped without one.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testDBOnce.Do(func() {
+		testDBConn, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if testDBErr != nil {
+			return
+		}
+		testDBErr = testDBConn.AutoMigrate(
+			&models.Permission{},
+			&models.Role{},
+			&models.User{},
+			&models.ExternalIdentity{},
+			&models.UserPermissionOverride{},
+			&models.CaptchaChallenge{},
+			&models.WebAuthnCredential{},
+			&models.Session{},
+			&models.RetiredRefreshToken{},
+		)
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to set up the test database: %v", testDBErr)
+	}
+
+	tx := testDBConn.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// CreateEmailVerification issues a token verifying the user's current email
+// and returns it in plain form for the email.
+func CreateEmailVerification(db *gorm.DB, user *models.User) (string, error) {
+	if user.Email == nil {
+		return "", errors.New("user has no email address")
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	verification := models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     *user.Email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Duration(config.AppConfig.EmailVerificationTTL) * time.Hour),
+	}
+	if err := db.Create(&verification).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyEmail consumes a verification token and marks the address verified,
+// provided it is still the user's email.
+func VerifyEmail(db *gorm.DB, token string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var verification models.EmailVerificationToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).First(&verification).Error; err != nil {
+			return ErrInvalidVerificationToken
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL", verification.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidVerificationToken
+		}
+
+		if err := tx.Where("id = ? AND email = ?", verification.UserID, verification.Email).First(&user).Error; err != nil {
+			return ErrInvalidVerificationToken
+		}
+		if err := tx.Model(&user).Update("email_verified", true).Error; err != nil {
+			return err
+		}
+
+		// Other links for the same address are no longer needed
+		return tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
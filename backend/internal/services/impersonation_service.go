@@ -0,0 +1,129 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/permissions"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const ImpersonatePermission = "IMPERSONATE"
+
+func init() {
+	permissions.Register(permissions.Definition{
+		Name:         ImpersonatePermission,
+		Description:  "Log in as another user",
+		DefaultRoles: []string{"admin"},
+	})
+}
+
+// Audit actions
+const (
+	AuditImpersonationStart = "impersonation.start"
+	AuditImpersonationStop  = "impersonation.stop"
+	AuditImpersonatedAction = "impersonation.request"
+)
+
+var (
+	ErrImpersonateSelf       = errors.New("cannot impersonate yourself")
+	ErrImpersonateInactive   = errors.New("user is inactive")
+	ErrImpersonatePrivileged = errors.New("user has permissions the impersonator lacks")
+	ErrImpersonatorInvalid   = errors.New("impersonator is inactive or no longer allowed to impersonate")
+)
+
+type ImpersonationToken struct {
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// StartImpersonation issues a token that acts as target on behalf of actor. The
+// target can't hold any permission the actor doesn't, so impersonation never
+// grants more access than the admin already has.
+func StartImpersonation(db *gorm.DB, actor, target *models.User, sessionID, ip string) (*ImpersonationToken, error) {
+	if actor.ID == target.ID {
+		return nil, ErrImpersonateSelf
+	}
+	if !target.IsActive {
+		return nil, ErrImpersonateInactive
+	}
+
+	actorPermissions, err := utils.GetUserPermissions(db, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	targetPermissions, err := utils.GetUserPermissions(db, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]bool, len(actorPermissions))
+	for _, name := range actorPermissions {
+		held[name] = true
+	}
+	for _, name := range targetPermissions {
+		if !held[name] {
+			return nil, ErrImpersonatePrivileged
+		}
+	}
+
+	ttl := time.Duration(config.AppConfig.ImpersonationTTL) * time.Minute
+	accessToken, expiresAt, err := utils.GenerateImpersonationJWT(
+		target.ID.String(), target.Username, target.RoleID.String(), sessionID,
+		utils.Actor{UserID: actor.ID.String(), Username: actor.Username}, ttl,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	targetID := target.ID
+	RecordAudit(db, models.AuditLog{
+		ActorID: actor.ID,
+		UserID:  &targetID,
+		Action:  AuditImpersonationStart,
+		IP:      ip,
+	})
+
+	return &ImpersonationToken{AccessToken: accessToken, ExpiresAt: expiresAt}, nil
+}
+
+// GetImpersonator loads the admin behind an impersonation token, checking they
+// are still active and still allowed to impersonate.
+func GetImpersonator(db *gorm.DB, actorID string) (*models.User, error) {
+	var actor models.User
+	if err := db.Where("id = ? AND is_active = ?", actorID, true).First(&actor).Error; err != nil {
+		return nil, ErrImpersonatorInvalid
+	}
+	if !utils.UserHasPermission(db, actor.ID, ImpersonatePermission) {
+		return nil, ErrImpersonatorInvalid
+	}
+	return &actor, nil
+}
+
+// RecordAudit stores an audit row. Failures to write are logged rather than
+// failing the request.
+func RecordAudit(db *gorm.DB, entry models.AuditLog) {
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit log: %v", err)
+	}
+}
+
+// ListAuditLogs returns the newest audit rows, optionally filtered by actor or
+// by the account acted as.
+func ListAuditLogs(db *gorm.DB, actorID, userID *uuid.UUID, limit int) ([]models.AuditLog, error) {
+	query := db.Preload("Actor").Preload("User").Order("created_at DESC").Limit(limit)
+	if actorID != nil {
+		query = query.Where("actor_id = ?", *actorID)
+	}
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var logs []models.AuditLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
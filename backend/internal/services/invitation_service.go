@@ -0,0 +1,136 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Registration modes
+const (
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationDisabled = "disabled"
+)
+
+// InvitationPrefix marks an invitation code.
+const InvitationPrefix = "inv_"
+
+var (
+	ErrInvitationInvalid  = errors.New("invitation is invalid, expired or used up")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrRoleNotHeld        = errors.New("role has permissions the inviter lacks")
+)
+
+// RegistrationMode returns the configured self-registration mode.
+func RegistrationMode() string {
+	return config.AppConfig.RegistrationMode
+}
+
+// ValidateRegistrationMode rejects unknown REGISTRATION_MODE values at startup.
+func ValidateRegistrationMode(mode string) error {
+	switch mode {
+	case RegistrationOpen, RegistrationInvite, RegistrationDisabled:
+		return nil
+	}
+	return fmt.Errorf("unsupported REGISTRATION_MODE %q (use open, invite or disabled)", mode)
+}
+
+// CreateInvitation creates an invitation for role. The inviter must hold every
+// permission of the role, so invitations can't hand out more access than they
+// have. The plain code is returned once and never stored.
+func CreateInvitation(db *gorm.DB, inviter *models.User, roleID uuid.UUID, maxUses int, expiresAt *time.Time, note string) (string, *models.Invitation, error) {
+	var role models.Role
+	if err := db.Where("id = ?", roleID).First(&role).Error; err != nil {
+		return "", nil, err
+	}
+	rolePermissions, err := utils.GetRolePermissionNames(db, role.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	held, err := utils.GetUserPermissions(db, inviter.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	heldSet := make(map[string]bool, len(held))
+	for _, perm := range held {
+		heldSet[perm] = true
+	}
+	for _, perm := range rolePermissions {
+		if !heldSet[perm] {
+			return "", nil, ErrRoleNotHeld
+		}
+	}
+
+	secret, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	code := InvitationPrefix + secret
+
+	invitation := models.Invitation{
+		Prefix:      code[:len(InvitationPrefix)+6],
+		CodeHash:    utils.HashToken(code),
+		Note:        note,
+		RoleID:      role.ID,
+		MaxUses:     maxUses,
+		ExpiresAt:   expiresAt,
+		CreatedByID: inviter.ID,
+	}
+	if err := db.Create(&invitation).Error; err != nil {
+		return "", nil, err
+	}
+	invitation.Role = role
+	return code, &invitation, nil
+}
+
+// RedeemInvitation uses up one use of an invitation and returns it. Run it in
+// the transaction that creates the account, so a failed registration doesn't
+// consume the code.
+func RedeemInvitation(tx *gorm.DB, code string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := tx.Where("code_hash = ?", utils.HashToken(code)).First(&invitation).Error; err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	// Conditional update so concurrent registrations can't overrun max_uses
+	result := tx.Model(&models.Invitation{}).
+		Where("id = ? AND revoked_at IS NULL AND uses < max_uses AND (expires_at IS NULL OR expires_at > ?)", invitation.ID, time.Now()).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvitationInvalid
+	}
+	invitation.Uses++
+	return &invitation, nil
+}
+
+// ListInvitations returns all invitations, newest first.
+func ListInvitations(db *gorm.DB) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := db.Preload("Role").Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+// RevokeInvitation stops an invitation from being redeemed again.
+func RevokeInvitation(db *gorm.DB, id uuid.UUID) error {
+	result := db.Model(&models.Invitation{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxLoginDelay = 30 * time.Second
+
+// Reasons recorded on login attempts
+const (
+	LoginReasonSuccess         = ""
+	LoginReasonUnknownUser     = "unknown_user"
+	LoginReasonInvalidPassword = "invalid_password"
+	LoginReasonInactive        = "inactive"
+	LoginReasonLocked          = "locked"
+	LoginReasonThrottled       = "throttled"
+	LoginReasonInvalidPasskey  = "invalid_passkey"
+	LoginReasonInvalidLink     = "invalid_magic_link"
+)
+
+// LoginRetryAfter returns how long the user has to wait before the next password
+// attempt, and whether that wait is a full lockout rather than a progressive delay.
+func LoginRetryAfter(user *models.User) (time.Duration, bool) {
+	now := time.Now()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now), true
+	}
+
+	if user.FailedLogins < 2 || user.LastFailedAt == nil {
+		return 0, false
+	}
+
+	// 1s after the second failure, doubling each time
+	delay := time.Second << uint(user.FailedLogins-2)
+	if delay > maxLoginDelay || delay <= 0 {
+		delay = maxLoginDelay
+	}
+	if wait := user.LastFailedAt.Add(delay).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
+
+// RecordFailedLogin bumps the user's failure counter and locks the account once
+// it reaches the configured maximum. The count is incremented in SQL, so
+// concurrent wrong guesses each count; user gets the new counter and lockout.
+func RecordFailedLogin(db *gorm.DB, user *models.User) error {
+	now := time.Now()
+	lockUntil := now.Add(time.Duration(config.AppConfig.LoginLockoutMinutes) * time.Minute)
+
+	// An expired lockout starts a fresh count
+	failures := "CASE WHEN locked_until IS NOT NULL AND locked_until <= ? THEN 1 ELSE failed_logins + 1 END"
+
+	return db.Model(user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_logins"}, {Name: "last_failed_at"}, {Name: "locked_until"}}}).
+		Updates(map[string]interface{}{
+			"failed_logins":  gorm.Expr(failures, now),
+			"last_failed_at": now,
+			"locked_until":   gorm.Expr("CASE WHEN "+failures+" >= ? THEN CAST(? AS timestamptz) END", now, config.AppConfig.LoginMaxFailures, lockUntil),
+		}).Error
+}
+
+// ResetFailedLogins clears the failure counter and any lockout.
+func ResetFailedLogins(db *gorm.DB, userID uuid.UUID) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_logins":  0,
+		"last_failed_at": nil,
+		"locked_until":   nil,
+	}).Error
+}
+
+// RecordLoginAttempt stores an audit row for a login attempt. Failures to write
+// are logged rather than failing the login.
+func RecordLoginAttempt(db *gorm.DB, attempt models.LoginAttempt) {
+	if err := db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
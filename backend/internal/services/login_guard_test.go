@@ -0,0 +1,54 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"testing"
+	"time"
+)
+
+func TestRecordFailedLoginCountsFromTheDatabase(t *testing.T) {
+	db := testDB(t)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{LoginMaxFailures: 3, LoginLockoutMinutes: 15}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	role := models.Role{Name: uniqueName("guard")}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{FullName: "Jane", Username: uniqueName("jane"), PasswordHash: "x", RoleID: role.ID, IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Each request works on the row it read before any of them failed
+	for i := 0; i < 3; i++ {
+		stale := user
+		if err := RecordFailedLogin(db, &stale); err != nil {
+			t.Fatalf("RecordFailedLogin: %v", err)
+		}
+		if stale.FailedLogins != i+1 {
+			t.Errorf("failure %d: FailedLogins = %d, want %d", i+1, stale.FailedLogins, i+1)
+		}
+	}
+
+	var stored models.User
+	db.First(&stored, "id = ?", user.ID)
+	if stored.FailedLogins != 3 {
+		t.Errorf("stored FailedLogins = %d, want 3", stored.FailedLogins)
+	}
+	if stored.LockedUntil == nil || !stored.LockedUntil.After(time.Now()) {
+		t.Errorf("LockedUntil = %v, want a lockout after the third failure", stored.LockedUntil)
+	}
+
+	// A failure after the lockout ran out starts a fresh count
+	expired := time.Now().Add(-time.Minute)
+	db.Model(&stored).Update("locked_until", expired)
+	if err := RecordFailedLogin(db, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLogins != 1 || stored.LockedUntil != nil {
+		t.Errorf("after an expired lockout: FailedLogins = %d, LockedUntil = %v; want 1 and none", stored.FailedLogins, stored.LockedUntil)
+	}
+}
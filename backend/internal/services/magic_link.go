@@ -0,0 +1,105 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMagicLink   = errors.New("invalid or expired magic link")
+	ErrMagicLinkNotForYou = errors.New("magic links are not enabled for this account")
+)
+
+// MagicLinkUser finds the active user a magic link may be sent to: the
+// address must be verified and every one of the user's roles must allow magic
+// links.
+func MagicLinkUser(db *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	if err := db.Preload("Role").Where("email = ? AND email_verified = ? AND is_active = ?", email, true, true).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if !rolesAllowMagicLink(db, &user) {
+		return nil, ErrMagicLinkNotForYou
+	}
+	return &user, nil
+}
+
+// CreateMagicLink issues a login token for the user and returns it in plain
+// form for the email. Earlier unused links stay valid until they expire.
+func CreateMagicLink(db *gorm.DB, user *models.User) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	link := models.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Duration(config.AppConfig.MagicLinkTTL) * time.Minute),
+	}
+	if err := db.Create(&link).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeMagicLink uses up a login token and returns its user, with the role
+// loaded. The user must still be active, verified and allowed magic links.
+// All of the user's other unused links are voided too.
+func ConsumeMagicLink(db *gorm.DB, token string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var link models.MagicLinkToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).First(&link).Error; err != nil {
+			return ErrInvalidMagicLink
+		}
+
+		// Claim the token; a concurrent use of the same link loses here
+		now := time.Now()
+		result := tx.Model(&models.MagicLinkToken{}).
+			Where("id = ? AND used_at IS NULL", link.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMagicLink
+		}
+		if err := tx.Model(&models.MagicLinkToken{}).
+			Where("user_id = ? AND used_at IS NULL", link.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Role").Where("id = ? AND is_active = ? AND email_verified = ?", link.UserID, true, true).First(&user).Error; err != nil {
+			return ErrInvalidMagicLink
+		}
+		if !rolesAllowMagicLink(tx, &user) {
+			return ErrMagicLinkNotForYou
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// rolesAllowMagicLink reports whether all of the user's roles allow magic
+// links, so holding one role that does can't bypass the password of another.
+func rolesAllowMagicLink(db *gorm.DB, user *models.User) bool {
+	roleIDs, err := utils.GetUserRoleIDs(db, user)
+	if err != nil {
+		return false
+	}
+	var count int64
+	if err := db.Model(&models.Role{}).Where("id IN ? AND allow_magic_link = ?", roleIDs, false).Count(&count).Error; err != nil {
+		return false
+	}
+	return count == 0
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"admin-dashboard/internal/utils"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAChallenge is a login that passed the password and captcha steps and is
+// waiting for a second factor.
+type MFAChallenge struct {
+	UserID uuid.UUID
+	// Enrolling is set when the user's role requires 2FA but they haven't set it up yet
+	Enrolling bool
+	// PasswordChange is set when the login must replace an expired password afterwards
+	PasswordChange bool
+	ExpiresAt      time.Time
+	Attempts       int
+}
+
+type MFAStore struct {
+	challenges  map[string]*MFAChallenge
+	mu          sync.Mutex
+	expiry      time.Duration
+	maxAttempts int
+}
+
+func NewMFAStore() *MFAStore {
+	store := &MFAStore{
+		challenges:  make(map[string]*MFAChallenge),
+		expiry:      5 * time.Minute,
+		maxAttempts: 5,
+	}
+
+	// Cleanup expired challenges periodically
+	go store.cleanup()
+
+	return store
+}
+
+// Create registers a pending second-factor step and returns its opaque token.
+func (s *MFAStore) Create(userID uuid.UUID, enrolling, passwordChange bool) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.challenges[token] = &MFAChallenge{
+		UserID:         userID,
+		Enrolling:      enrolling,
+		PasswordChange: passwordChange,
+		ExpiresAt:      time.Now().Add(s.expiry),
+	}
+	s.mu.Unlock()
+	return token, nil
+}
+
+// Get returns a copy of the challenge if it exists and hasn't expired.
+func (s *MFAStore) Get(token string) (MFAChallenge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[token]
+	if !exists {
+		return MFAChallenge{}, false
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		delete(s.challenges, token)
+		return MFAChallenge{}, false
+	}
+	return *challenge, true
+}
+
+// UseAttempt counts an answer against the challenge and returns a copy of it,
+// before the code is checked, so concurrent answers can't get past the limit.
+// The challenge is dropped when its last attempt is handed out; it returns false
+// once none are left, or if the challenge doesn't exist or has expired.
+func (s *MFAStore) UseAttempt(token string) (MFAChallenge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[token]
+	if !exists {
+		return MFAChallenge{}, false
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= s.maxAttempts {
+		delete(s.challenges, token)
+		return MFAChallenge{}, false
+	}
+	challenge.Attempts++
+	if challenge.Attempts >= s.maxAttempts {
+		delete(s.challenges, token)
+	}
+	return *challenge, true
+}
+
+// Consume removes a challenge after the login completed.
+func (s *MFAStore) Consume(token string) {
+	s.mu.Lock()
+	delete(s.challenges, token)
+	s.mu.Unlock()
+}
+
+func (s *MFAStore) cleanup() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for token, challenge := range s.challenges {
+			if now.After(challenge.ExpiresAt) {
+				delete(s.challenges, token)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
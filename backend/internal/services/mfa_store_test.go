@@ -0,0 +1,69 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMFAStoreUseAttempt(t *testing.T) {
+	store := NewMFAStore()
+	userID := uuid.New()
+
+	token, err := store.Create(userID, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= store.maxAttempts; i++ {
+		challenge, ok := store.UseAttempt(token)
+		if !ok || challenge.UserID != userID || !challenge.PasswordChange || challenge.Attempts != i {
+			t.Fatalf("attempt %d = %+v, %v", i, challenge, ok)
+		}
+	}
+	if _, ok := store.UseAttempt(token); ok {
+		t.Error("an attempt was handed out past the limit")
+	}
+	if _, ok := store.Get(token); ok {
+		t.Error("the challenge survived its last attempt")
+	}
+}
+
+func TestMFAStoreUseAttemptConcurrently(t *testing.T) {
+	store := NewMFAStore()
+	token, err := store.Create(uuid.New(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := store.UseAttempt(token); ok {
+				granted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := int(granted.Load()); n != store.maxAttempts {
+		t.Errorf("%d attempts were handed out, want %d", n, store.maxAttempts)
+	}
+}
+
+func TestMFAStoreUseAttemptExpired(t *testing.T) {
+	store := NewMFAStore()
+	store.expiry = -1
+
+	token, err := store.Create(uuid.New(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.UseAttempt(token); ok {
+		t.Error("an expired challenge was accepted")
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/oidc"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoMappedRole     = errors.New("none of the user's groups map to a role")
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+
+	usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// OIDCLoginState is what we remember between redirecting to the provider and its callback.
+type OIDCLoginState struct {
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// OIDCStateStore holds pending authorization requests, keyed by state.
+type OIDCStateStore struct {
+	states map[string]OIDCLoginState
+	mu     sync.Mutex
+	expiry time.Duration
+}
+
+func NewOIDCStateStore() *OIDCStateStore {
+	store := &OIDCStateStore{
+		states: make(map[string]OIDCLoginState),
+		expiry: 10 * time.Minute,
+	}
+
+	// Cleanup abandoned logins periodically
+	go store.cleanup()
+
+	return store
+}
+
+// Begin creates a new pending login and returns its state, nonce and PKCE verifier.
+func (s *OIDCStateStore) Begin() (string, OIDCLoginState, error) {
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", OIDCLoginState{}, err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", OIDCLoginState{}, err
+	}
+	verifier, err := utils.GenerateRandomToken(48)
+	if err != nil {
+		return "", OIDCLoginState{}, err
+	}
+
+	login := OIDCLoginState{
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.expiry),
+	}
+	s.mu.Lock()
+	s.states[state] = login
+	s.mu.Unlock()
+	return state, login, nil
+}
+
+// Consume returns and removes a pending login. Each state can only be used once.
+func (s *OIDCStateStore) Consume(state string) (OIDCLoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, exists := s.states[state]
+	if !exists {
+		return OIDCLoginState{}, ErrInvalidOIDCState
+	}
+	delete(s.states, state)
+	if time.Now().After(login.ExpiresAt) {
+		return OIDCLoginState{}, ErrInvalidOIDCState
+	}
+	return login, nil
+}
+
+func (s *OIDCStateStore) cleanup() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for state, login := range s.states {
+			if now.After(login.ExpiresAt) {
+				delete(s.states, state)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ResolveOIDCRole maps IdP groups to a role through OIDC_ROLE_MAPPING. The first
+// mapping entry that matches one of the groups wins; otherwise OIDC_DEFAULT_ROLE
+// is used if set.
+func ResolveOIDCRole(db *gorm.DB, groups []string) (*models.Role, error) {
+	member := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		member[group] = true
+	}
+
+	roleName := config.AppConfig.OIDCDefaultRole
+	for _, entry := range strings.Split(config.AppConfig.OIDCRoleMapping, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && member[strings.TrimSpace(group)] {
+			roleName = strings.TrimSpace(role)
+			break
+		}
+	}
+	if roleName == "" {
+		return nil, ErrNoMappedRole
+	}
+
+	var role models.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("mapped role %q not found: %w", roleName, err)
+	}
+	return &role, nil
+}
+
+// ProvisionOIDCUser returns the user linked to the provider identity, creating
+// one on first login. The role is re-synced from the IdP groups on every login,
+// so the IdP stays the source of truth for SSO users.
+func ProvisionOIDCUser(db *gorm.DB, claims *oidc.IDTokenClaims) (*models.User, error) {
+	role, err := ResolveOIDCRole(db, claims.StringsClaim(config.AppConfig.OIDCGroupsClaim))
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := config.AppConfig.OIDCIssuer
+	var user models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var identity models.ExternalIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			if err := tx.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			if user.RoleID != role.ID {
+				if err := ReplacePrimaryRole(tx, &user, role.ID); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&identity).Update("last_login_at", time.Now()).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// First login: create a local account without a usable password
+		randomPassword, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := utils.HashPassword(randomPassword)
+		if err != nil {
+			return err
+		}
+
+		username, err := uniqueUsername(tx, oidcUsernameCandidate(claims))
+		if err != nil {
+			return err
+		}
+
+		fullName := claims.Name
+		if fullName == "" {
+			fullName = username
+		}
+
+		user = models.User{
+			FullName:     fullName,
+			Username:     username,
+			PasswordHash: hashedPassword,
+			RoleID:       role.ID,
+			IsActive:     true,
+		}
+		if email := strings.ToLower(strings.TrimSpace(claims.Email)); email != "" && claims.EmailVerified {
+			var count int64
+			tx.Model(&models.User{}).Where("email = ?", email).Count(&count)
+			if count == 0 {
+				user.Email = &email
+				user.EmailVerified = true
+			}
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:      user.ID,
+			Issuer:      issuer,
+			Subject:     claims.Subject,
+			LastLoginAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.Preload("Role").First(&user, user.ID)
+	return &user, nil
+}
+
+func oidcUsernameCandidate(claims *oidc.IDTokenClaims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" && claims.Email != "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+	candidate = usernameInvalidChars.ReplaceAllString(candidate, "-")
+	candidate = strings.Trim(candidate, "-")
+	if len(candidate) > 40 {
+		candidate = candidate[:40]
+	}
+	if len(candidate) < 3 {
+		candidate = "sso-user"
+	}
+	return candidate
+}
+
+// uniqueUsername appends a numeric suffix until the username is free.
+func uniqueUsername(db *gorm.DB, base string) (string, error) {
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		var count int64
+		if err := db.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", errors.New("could not find a free username")
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/oidc"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestOIDCStateStore(t *testing.T) {
+	store := NewOIDCStateStore()
+
+	state, login, err := store.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if login.Nonce == "" || login.CodeVerifier == "" || login.Nonce == login.CodeVerifier {
+		t.Fatalf("Begin returned a weak login: %+v", login)
+	}
+
+	if _, err := store.Consume("not-" + state); err != ErrInvalidOIDCState {
+		t.Errorf("Consume(unknown state) = %v, want ErrInvalidOIDCState", err)
+	}
+
+	got, err := store.Consume(state)
+	if err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	if got.Nonce != login.Nonce || got.CodeVerifier != login.CodeVerifier {
+		t.Errorf("Consume returned %+v, want %+v", got, login)
+	}
+
+	if _, err := store.Consume(state); err != ErrInvalidOIDCState {
+		t.Errorf("second Consume = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCStateStoreExpiry(t *testing.T) {
+	store := NewOIDCStateStore()
+	store.expiry = -time.Second
+
+	state, _, err := store.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Consume(state); err != ErrInvalidOIDCState {
+		t.Errorf("Consume(expired state) = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCUsernameCandidate(t *testing.T) {
+	tests := []struct {
+		claims oidc.IDTokenClaims
+		want   string
+	}{
+		{oidc.IDTokenClaims{PreferredUsername: "jane.doe"}, "jane-doe"},
+		{oidc.IDTokenClaims{Email: "john+work@example.com"}, "john-work"},
+		{oidc.IDTokenClaims{PreferredUsername: "..."}, "sso-user"},
+		{oidc.IDTokenClaims{}, "sso-user"},
+		{oidc.IDTokenClaims{PreferredUsername: strings.Repeat("a", 60)}, strings.Repeat("a", 40)},
+	}
+	for _, tt := range tests {
+		if got := oidcUsernameCandidate(&tt.claims); got != tt.want {
+			t.Errorf("oidcUsernameCandidate(%+v) = %q, want %q", tt.claims, got, tt.want)
+		}
+	}
+}
+
+// oidcFixture sets up two roles mapped from IdP groups and an SSO config for them.
+type oidcFixture struct {
+	db    *gorm.DB
+	staff models.Role
+	admin models.Role
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	db := testDB(t)
+
+	f := &oidcFixture{db: db}
+	suffix := uuid.NewString()[:8]
+	f.staff = models.Role{Name: "sso-staff-" + suffix}
+	f.admin = models.Role{Name: "sso-admin-" + suffix}
+	for _, role := range []*models.Role{&f.staff, &f.admin} {
+		if err := db.Create(role).Error; err != nil {
+			t.Fatalf("failed to create role: %v", err)
+		}
+	}
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		OIDCIssuer:      "https://idp.example.com",
+		OIDCGroupsClaim: "groups",
+		OIDCRoleMapping: "admins=" + f.admin.Name + ", staff=" + f.staff.Name,
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+	return f
+}
+
+func (f *oidcFixture) claims(subject, username, email string, emailVerified bool, groups ...string) *oidc.IDTokenClaims {
+	raw := map[string]interface{}{}
+	list := make([]interface{}, len(groups))
+	for i, group := range groups {
+		list[i] = group
+	}
+	raw["groups"] = list
+	return &oidc.IDTokenClaims{
+		Subject:           subject,
+		Email:             email,
+		EmailVerified:     emailVerified,
+		PreferredUsername: username,
+		Raw:               raw,
+	}
+}
+
+func (f *oidcFixture) createUser(t *testing.T, username string, email *string) models.User {
+	t.Helper()
+	user := models.User{FullName: username, Username: username, Email: email, PasswordHash: "x", RoleID: f.staff.ID, IsActive: true}
+	if err := f.db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+func uniqueName(prefix string) string {
+	return prefix + "-" + uuid.NewString()[:8]
+}
+
+func TestProvisionOIDCUserCreatesAccount(t *testing.T) {
+	f := newOIDCFixture(t)
+	username := uniqueName("jane")
+	email := username + "@example.com"
+
+	user, err := ProvisionOIDCUser(f.db, f.claims("sub-1", username, email, true, "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionOIDCUser: %v", err)
+	}
+
+	if user.Username != username || user.RoleID != f.staff.ID || !user.IsActive {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if user.Email == nil || *user.Email != email || !user.EmailVerified {
+		t.Errorf("verified email not taken over: %v, verified %v", user.Email, user.EmailVerified)
+	}
+
+	var identity models.ExternalIdentity
+	if err := f.db.Where("issuer = ? AND subject = ?", "https://idp.example.com", "sub-1").First(&identity).Error; err != nil {
+		t.Fatalf("identity not linked: %v", err)
+	}
+	if identity.UserID != user.ID {
+		t.Errorf("identity linked to %s, want %s", identity.UserID, user.ID)
+	}
+}
+
+func TestProvisionOIDCUserLinksReturningUser(t *testing.T) {
+	f := newOIDCFixture(t)
+	username := uniqueName("jane")
+
+	first, err := ProvisionOIDCUser(f.db, f.claims("sub-1", username, "", false, "staff"))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// The IdP moved the user to another group; the role follows it
+	second, err := ProvisionOIDCUser(f.db, f.claims("sub-1", "renamed-at-idp", "", false, "admins"))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Fatalf("second login created user %s, want the linked user %s", second.ID, first.ID)
+	}
+	if second.Username != username {
+		t.Errorf("Username = %q, want it unchanged (%q)", second.Username, username)
+	}
+	if second.RoleID != f.admin.ID {
+		t.Errorf("RoleID = %s, want the re-synced role %s", second.RoleID, f.admin.ID)
+	}
+
+	var heldRoles []uuid.UUID
+	f.db.Table("user_roles").Where("user_id = ?", second.ID).Pluck("role_id", &heldRoles)
+	if len(heldRoles) != 1 || heldRoles[0] != f.admin.ID {
+		t.Errorf("role set = %v, want only %s", heldRoles, f.admin.ID)
+	}
+}
+
+func TestProvisionOIDCUserScopesIdentitiesByIssuer(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	first, err := ProvisionOIDCUser(f.db, f.claims("sub-1", uniqueName("jane"), "", false, "staff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same subject at another issuer is a different person
+	config.AppConfig.OIDCIssuer = "https://other-idp.example.com"
+	second, err := ProvisionOIDCUser(f.db, f.claims("sub-1", uniqueName("john"), "", false, "staff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("an identity from another issuer was linked to an existing user")
+	}
+}
+
+func TestProvisionOIDCUserNeverLinksByEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	email := uniqueName("taken") + "@example.com"
+	existing := f.createUser(t, uniqueName("local"), &email)
+
+	// A verified address that belongs to a local account must not sign in as it
+	user, err := ProvisionOIDCUser(f.db, f.claims("sub-1", uniqueName("jane"), email, true, "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionOIDCUser: %v", err)
+	}
+	if user.ID == existing.ID {
+		t.Fatal("SSO login was linked to a local account by email")
+	}
+	if user.Email != nil {
+		t.Errorf("Email = %q, want none since it is taken", *user.Email)
+	}
+}
+
+func TestProvisionOIDCUserIgnoresUnverifiedEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	user, err := ProvisionOIDCUser(f.db, f.claims("sub-1", uniqueName("jane"), uniqueName("jane")+"@example.com", false, "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionOIDCUser: %v", err)
+	}
+	if user.Email != nil {
+		t.Errorf("Email = %q, want none for an unverified address", *user.Email)
+	}
+}
+
+func TestProvisionOIDCUserPicksFreeUsername(t *testing.T) {
+	f := newOIDCFixture(t)
+	username := uniqueName("jane")
+	f.createUser(t, username, nil)
+
+	user, err := ProvisionOIDCUser(f.db, f.claims("sub-1", username, "", false, "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionOIDCUser: %v", err)
+	}
+	if user.Username != username+"-2" {
+		t.Errorf("Username = %q, want %q", user.Username, username+"-2")
+	}
+}
+
+func TestProvisionOIDCUserWithoutMappedRole(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	_, err := ProvisionOIDCUser(f.db, f.claims("sub-1", uniqueName("jane"), "", false, "contractors"))
+	if !errors.Is(err, ErrNoMappedRole) {
+		t.Fatalf("error = %v, want ErrNoMappedRole", err)
+	}
+
+	var count int64
+	f.db.Model(&models.ExternalIdentity{}).Where("subject = ?", "sub-1").Count(&count)
+	if count != 0 {
+		t.Error("an identity was linked although no role applies")
+	}
+}
+
+func TestResolveOIDCRoleUsesFirstMatchingMapping(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	// Mapping order decides, not the order of the user's groups
+	role, err := ResolveOIDCRole(f.db, []string{"staff", "admins"})
+	if err != nil {
+		t.Fatalf("ResolveOIDCRole: %v", err)
+	}
+	if role.ID != f.admin.ID {
+		t.Errorf("role = %s, want %s", role.Name, f.admin.Name)
+	}
+
+	config.AppConfig.OIDCDefaultRole = f.staff.Name
+	role, err = ResolveOIDCRole(f.db, []string{"contractors"})
+	if err != nil || role.ID != f.staff.ID {
+		t.Errorf("ResolveOIDCRole without a matching group = %v, %v; want the default role", role, err)
+	}
+}
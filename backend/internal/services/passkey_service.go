@@ -0,0 +1,285 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/webauthn"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPasskeyInvalid  = errors.New("passkey verification failed")
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyExists   = errors.New("passkey already registered")
+)
+
+// PasskeyCeremony is a registration or login started by the server and
+// waiting for the browser's response. It is keyed by its challenge.
+type PasskeyCeremony struct {
+	// UserID is the registering user, or for logins the user named in the
+	// request (uuid.Nil when the browser picks a discoverable passkey)
+	UserID       uuid.UUID
+	Registration bool
+	ExpiresAt    time.Time
+}
+
+type PasskeyCeremonyStore struct {
+	ceremonies map[string]PasskeyCeremony
+	mu         sync.Mutex
+	expiry     time.Duration
+}
+
+func NewPasskeyCeremonyStore() *PasskeyCeremonyStore {
+	store := &PasskeyCeremonyStore{
+		ceremonies: make(map[string]PasskeyCeremony),
+		expiry:     5 * time.Minute,
+	}
+
+	// Cleanup expired ceremonies periodically
+	go store.cleanup()
+
+	return store
+}
+
+// start issues a new challenge for a ceremony.
+func (s *PasskeyCeremonyStore) start(userID uuid.UUID, registration bool) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.ceremonies[base64.RawURLEncoding.EncodeToString(challenge)] = PasskeyCeremony{
+		UserID:       userID,
+		Registration: registration,
+		ExpiresAt:    time.Now().Add(s.expiry),
+	}
+	s.mu.Unlock()
+	return challenge, nil
+}
+
+// take consumes the ceremony a response belongs to. Each challenge can be answered once.
+func (s *PasskeyCeremonyStore) take(challenge []byte, registration bool) (PasskeyCeremony, bool) {
+	key := base64.RawURLEncoding.EncodeToString(challenge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ceremony, exists := s.ceremonies[key]
+	if !exists {
+		return PasskeyCeremony{}, false
+	}
+	delete(s.ceremonies, key)
+
+	if ceremony.Registration != registration || time.Now().After(ceremony.ExpiresAt) {
+		return PasskeyCeremony{}, false
+	}
+	return ceremony, true
+}
+
+func (s *PasskeyCeremonyStore) cleanup() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for key, ceremony := range s.ceremonies {
+			if now.After(ceremony.ExpiresAt) {
+				delete(s.ceremonies, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create().
+func BeginPasskeyRegistration(db *gorm.DB, store *PasskeyCeremonyStore, user *models.User) (*webauthn.CreationOptions, error) {
+	existing, err := ListPasskeys(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := store.start(user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	options := webauthn.Default.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          user.ID[:],
+		Name:        user.Username,
+		DisplayName: user.FullName,
+	}, credentialDescriptors(existing))
+	return &options, nil
+}
+
+// FinishPasskeyRegistration verifies the browser's response and stores the new passkey.
+func FinishPasskeyRegistration(db *gorm.DB, store *PasskeyCeremonyStore, user *models.User, name string, resp *webauthn.RegistrationResponse) (*models.WebAuthnCredential, error) {
+	challenge, err := webauthn.ChallengeOf(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+	ceremony, ok := store.take(challenge, true)
+	if !ok || ceremony.UserID != user.ID {
+		return nil, ErrPasskeyInvalid
+	}
+
+	verified, err := webauthn.Default.VerifyRegistration(challenge, resp)
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+
+	var count int64
+	if err := db.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", verified.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrPasskeyExists
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential := models.WebAuthnCredential{
+		UserID:         user.ID,
+		Name:           name,
+		CredentialID:   verified.ID,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      int64(verified.SignCount),
+		AAGUID:         verified.AAGUID,
+		Transports:     strings.Join(verified.Transports, ","),
+		BackupEligible: verified.BackupEligible,
+	}
+	if err := db.Create(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get(). With
+// a username the user's passkeys are listed, which also lets security keys
+// without discoverable credentials work; without one the browser offers any
+// passkey it holds for this site.
+func BeginPasskeyLogin(db *gorm.DB, store *PasskeyCeremonyStore, username string) (*webauthn.RequestOptions, error) {
+	userID := uuid.Nil
+	var allow []webauthn.CredentialDescriptor
+
+	if username != "" {
+		var user models.User
+		if err := db.Where("username = ? AND is_active = ?", username, true).First(&user).Error; err == nil {
+			credentials, err := ListPasskeys(db, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			userID = user.ID
+			allow = credentialDescriptors(credentials)
+		}
+	}
+
+	challenge, err := store.start(userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	options := webauthn.Default.RequestOptions(challenge, allow)
+	return &options, nil
+}
+
+// FinishPasskeyLogin verifies a login response and returns the user, with
+// their role loaded, and whether the authenticator verified the user (PIN or
+// biometrics), which makes the passkey count as two factors.
+func FinishPasskeyLogin(db *gorm.DB, store *PasskeyCeremonyStore, resp *webauthn.AssertionResponse) (*models.User, bool, error) {
+	challenge, err := webauthn.ChallengeOf(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, false, ErrPasskeyInvalid
+	}
+	ceremony, ok := store.take(challenge, false)
+	if !ok {
+		return nil, false, ErrPasskeyInvalid
+	}
+
+	var credential models.WebAuthnCredential
+	if err := db.Where("credential_id = ?", []byte(resp.RawID)).First(&credential).Error; err != nil {
+		return nil, false, ErrPasskeyInvalid
+	}
+	if ceremony.UserID != uuid.Nil && ceremony.UserID != credential.UserID {
+		return nil, false, ErrPasskeyInvalid
+	}
+	if len(resp.Response.UserHandle) > 0 && !bytes.Equal(resp.Response.UserHandle, credential.UserID[:]) {
+		return nil, false, ErrPasskeyInvalid
+	}
+
+	result, err := webauthn.Default.VerifyAssertion(challenge, resp, credential.PublicKey, uint32(credential.SignCount))
+	if err != nil {
+		return nil, false, ErrPasskeyInvalid
+	}
+
+	now := time.Now()
+	if err := db.Model(&credential).Updates(map[string]interface{}{
+		"sign_count":   int64(result.SignCount),
+		"last_used_at": now,
+	}).Error; err != nil {
+		return nil, false, err
+	}
+
+	var user models.User
+	if err := db.Preload("Role").Where("id = ? AND is_active = ?", credential.UserID, true).First(&user).Error; err != nil {
+		return nil, false, ErrPasskeyInvalid
+	}
+	return &user, result.UserVerified, nil
+}
+
+// ListPasskeys returns the user's passkeys, oldest first.
+func ListPasskeys(db *gorm.DB, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// RenamePasskey changes the display name of one of the user's passkeys.
+func RenamePasskey(db *gorm.DB, userID, id uuid.UUID, name string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error; err != nil {
+		return nil, ErrPasskeyNotFound
+	}
+	if err := db.Model(&credential).Update("name", strings.TrimSpace(name)).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// DeletePasskey removes one of the user's passkeys.
+func DeletePasskey(db *gorm.DB, userID, id uuid.UUID) error {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func credentialDescriptors(credentials []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = webauthn.CredentialDescriptor{
+			Type: "public-key",
+			ID:   credential.CredentialID,
+		}
+		if credential.Transports != "" {
+			descriptors[i].Transports = strings.Split(credential.Transports, ",")
+		}
+	}
+	return descriptors
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/webauthn"
+	"admin-dashboard/internal/webauthn/webauthntest"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestPasskeyCeremonyStoreTake(t *testing.T) {
+	store := NewPasskeyCeremonyStore()
+	userID := uuid.New()
+
+	challenge, err := store.start(userID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.take([]byte("unknown"), true); ok {
+		t.Error("took a ceremony for an unknown challenge")
+	}
+
+	ceremony, ok := store.take(challenge, true)
+	if !ok || ceremony.UserID != userID || !ceremony.Registration {
+		t.Fatalf("take = %+v, %v; want the registration for %s", ceremony, ok, userID)
+	}
+	if _, ok := store.take(challenge, true); ok {
+		t.Error("a challenge was answered twice")
+	}
+}
+
+func TestPasskeyCeremonyStoreTakeWrongKind(t *testing.T) {
+	store := NewPasskeyCeremonyStore()
+
+	challenge, err := store.start(uuid.Nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A login challenge can't finish a registration, and is used up trying
+	if _, ok := store.take(challenge, true); ok {
+		t.Error("a login challenge finished a registration")
+	}
+	if _, ok := store.take(challenge, false); ok {
+		t.Error("the challenge survived a failed take")
+	}
+}
+
+func TestPasskeyCeremonyStoreExpiry(t *testing.T) {
+	store := NewPasskeyCeremonyStore()
+	store.expiry = -time.Second
+
+	challenge, err := store.start(uuid.Nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.take(challenge, false); ok {
+		t.Error("an expired challenge was accepted")
+	}
+}
+
+// passkeyFixture is a user with a passkey held by a software authenticator.
+type passkeyFixture struct {
+	db            *gorm.DB
+	store         *PasskeyCeremonyStore
+	user          models.User
+	authenticator *webauthntest.Authenticator
+}
+
+func newPasskeyFixture(t *testing.T) *passkeyFixture {
+	t.Helper()
+	db := testDB(t)
+
+	previous := webauthn.Default
+	webauthn.Default = webauthn.Config{RPID: "dashboard.example.com", Origins: []string{"https://dashboard.example.com"}, Timeout: time.Minute}
+	t.Cleanup(func() { webauthn.Default = previous })
+
+	f := &passkeyFixture{
+		db:            db,
+		store:         NewPasskeyCeremonyStore(),
+		authenticator: webauthntest.NewES256("dashboard.example.com", "https://dashboard.example.com"),
+	}
+	f.user = f.createUser(t)
+	f.authenticator.UserHandle = f.user.ID[:]
+
+	options, err := BeginPasskeyRegistration(db, f.store, &f.user)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if _, err := FinishPasskeyRegistration(db, f.store, &f.user, "Laptop", f.authenticator.Register(options.Challenge)); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	return f
+}
+
+func (f *passkeyFixture) createUser(t *testing.T) models.User {
+	t.Helper()
+	role := models.Role{Name: uniqueName("passkey")}
+	if err := f.db.Create(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{FullName: "Jane", Username: uniqueName("jane"), PasswordHash: "x", RoleID: role.ID, IsActive: true}
+	if err := f.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// beginLogin starts a login, for username if given.
+func (f *passkeyFixture) beginLogin(t *testing.T, username string) []byte {
+	t.Helper()
+	options, err := BeginPasskeyLogin(f.db, f.store, username)
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	return options.Challenge
+}
+
+func TestFinishPasskeyLogin(t *testing.T) {
+	f := newPasskeyFixture(t)
+
+	user, verified, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(f.beginLogin(t, "")))
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if user.ID != f.user.ID || !verified {
+		t.Errorf("logged in as %s (verified %v), want %s verified", user.ID, verified, f.user.ID)
+	}
+
+	var credential models.WebAuthnCredential
+	f.db.Where("user_id = ?", f.user.ID).First(&credential)
+	if credential.SignCount != 1 || credential.LastUsedAt == nil {
+		t.Errorf("credential not updated: sign count %d, last used %v", credential.SignCount, credential.LastUsedAt)
+	}
+}
+
+func TestFinishPasskeyLoginRejectsReusedChallenge(t *testing.T) {
+	f := newPasskeyFixture(t)
+	challenge := f.beginLogin(t, "")
+
+	resp := f.authenticator.Login(challenge)
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, resp); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// A fresh signature over the same challenge
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(challenge)); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("second login = %v, want ErrPasskeyInvalid", err)
+	}
+}
+
+func TestFinishPasskeyLoginRejectsSignCountRegression(t *testing.T) {
+	f := newPasskeyFixture(t)
+
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(f.beginLogin(t, ""))); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// A clone of the authenticator as it was before that login
+	f.authenticator.SignCount = 0
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(f.beginLogin(t, ""))); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("login from the clone = %v, want ErrPasskeyInvalid", err)
+	}
+}
+
+func TestFinishPasskeyLoginRejectsOtherUserHandle(t *testing.T) {
+	f := newPasskeyFixture(t)
+	other := f.createUser(t)
+
+	f.authenticator.UserHandle = other.ID[:]
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(f.beginLogin(t, ""))); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("login with another user's handle = %v, want ErrPasskeyInvalid", err)
+	}
+}
+
+func TestFinishPasskeyLoginRejectsOtherUsersCeremony(t *testing.T) {
+	f := newPasskeyFixture(t)
+	other := f.createUser(t)
+
+	// The login was started for another user, who has no passkey
+	if _, _, err := FinishPasskeyLogin(f.db, f.store, f.authenticator.Login(f.beginLogin(t, other.Username))); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("login for another user = %v, want ErrPasskeyInvalid", err)
+	}
+}
+
+func TestFinishPasskeyRegistrationRejectsOtherUsersCeremony(t *testing.T) {
+	f := newPasskeyFixture(t)
+	other := f.createUser(t)
+
+	options, err := BeginPasskeyRegistration(f.db, f.store, &other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := webauthntest.NewEd25519("dashboard.example.com", "https://dashboard.example.com")
+	if _, err := FinishPasskeyRegistration(f.db, f.store, &f.user, "Phone", authenticator.Register(options.Challenge)); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("registration on another user's ceremony = %v, want ErrPasskeyInvalid", err)
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicyError lists every rule a candidate password broke, so the
+// client can show them all at once.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+//go:embed common_passwords.txt
+var embeddedCommonPasswords string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// loadCommonPasswords builds the blocklist from the embedded list plus the
+// optional PASSWORD_BLOCKLIST_FILE.
+func loadCommonPasswords() {
+	commonPasswords = make(map[string]struct{})
+	addCommonPasswords(strings.NewReader(embeddedCommonPasswords))
+
+	if path := config.AppConfig.PasswordBlocklistFile; path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("Warning: could not open password blocklist %s: %v", path, err)
+			return
+		}
+		defer f.Close()
+		addCommonPasswords(f)
+	}
+}
+
+func addCommonPasswords(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commonPasswords[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+func isCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(loadCommonPasswords)
+	_, found := commonPasswords[strings.ToLower(password)]
+	return found
+}
+
+// GetPasswordPolicy returns the current policy, creating the default row on first use.
+func GetPasswordPolicy(db *gorm.DB) (*models.PasswordPolicy, error) {
+	policy := models.PasswordPolicy{
+		MinLength:   8,
+		BlockCommon: true,
+	}
+	if err := db.Where(models.PasswordPolicy{ID: 1}).FirstOrCreate(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// CheckPasswordPolicy validates the password against the policy's static
+// rules. History is checked separately because it needs the user's hashes.
+func CheckPasswordPolicy(policy *models.PasswordPolicy, username, password string) error {
+	var violations []string
+
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if policy.BlockCommon {
+		if isCommonPassword(password) {
+			violations = append(violations, "is too common")
+		} else if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+			violations = append(violations, "must not contain the username")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// checkPasswordHistory rejects a password matching the user's current one or
+// any of the last HistoryCount ones.
+func checkPasswordHistory(db *gorm.DB, policy *models.PasswordPolicy, user *models.User, password string) error {
+	if policy.HistoryCount <= 0 {
+		return nil
+	}
+	if utils.CheckPasswordHash(password, user.PasswordHash) {
+		return &PasswordPolicyError{Violations: []string{"must differ from your current password"}}
+	}
+
+	var history []models.PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(policy.HistoryCount).
+		Find(&history).Error; err != nil {
+		return err
+	}
+	for _, h := range history {
+		if utils.CheckPasswordHash(password, h.PasswordHash) {
+			return &PasswordPolicyError{Violations: []string{
+				fmt.Sprintf("must not match any of your last %d passwords", policy.HistoryCount),
+			}}
+		}
+	}
+	return nil
+}
+
+// HashNewPassword validates a password for a user that doesn't exist yet and
+// returns its hash. Call RecordPasswordHistory once the user is created.
+func HashNewPassword(db *gorm.DB, username, password string) (string, error) {
+	policy, err := GetPasswordPolicy(db)
+	if err != nil {
+		return "", err
+	}
+	if err := CheckPasswordPolicy(policy, username, password); err != nil {
+		return "", err
+	}
+	return utils.HashPassword(password)
+}
+
+// RecordPasswordHistory stores the hash in the user's history and trims
+// entries the policy no longer needs.
+func RecordPasswordHistory(db *gorm.DB, userID uuid.UUID, passwordHash string) error {
+	policy, err := GetPasswordPolicy(db)
+	if err != nil {
+		return err
+	}
+	if policy.HistoryCount <= 0 {
+		return db.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error
+	}
+
+	if err := db.Create(&models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+		return err
+	}
+
+	keep := db.Model(&models.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(policy.HistoryCount)
+	return db.Where("user_id = ? AND id NOT IN (?)", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+// ChangePassword validates the new password against the policy and the user's
+// history, then stores it and clears any forced-change flag.
+func ChangePassword(db *gorm.DB, user *models.User, password string) error {
+	policy, err := GetPasswordPolicy(db)
+	if err != nil {
+		return err
+	}
+	if err := CheckPasswordPolicy(policy, user.Username, password); err != nil {
+		return err
+	}
+	if err := checkPasswordHistory(db, policy, user, password); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"password_hash":        hash,
+			"password_changed_at":  now,
+			"must_change_password": false,
+		}).Error; err != nil {
+			return err
+		}
+		return RecordPasswordHistory(tx, user.ID, hash)
+	})
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+	return nil
+}
+
+// UpgradePasswordHash rehashes a just-verified password with the current
+// algorithm and parameters. It doesn't count as a password change, and is
+// skipped if the password changed in the meantime.
+func UpgradePasswordHash(db *gorm.DB, user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	result := db.Model(&models.User{}).
+		Where("id = ? AND password_hash = ?", user.ID, user.PasswordHash).
+		Update("password_hash", hash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		user.PasswordHash = hash
+	}
+	return nil
+}
+
+// PasswordChangeRequired reports whether the user must pick a new password
+// before logging in, either because an admin asked for it or it expired.
+func PasswordChangeRequired(db *gorm.DB, user *models.User) (bool, error) {
+	if user.MustChangePassword {
+		return true, nil
+	}
+
+	policy, err := GetPasswordPolicy(db)
+	if err != nil {
+		return false, err
+	}
+	if policy.MaxAgeDays <= 0 {
+		return false, nil
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour, nil
+}
+
+// IsPasswordPolicyError reports whether err is a policy rejection rather than an internal failure.
+func IsPasswordPolicyError(err error) (*PasswordPolicyError, bool) {
+	var policyErr *PasswordPolicyError
+	ok := errors.As(err, &policyErr)
+	return policyErr, ok
+}
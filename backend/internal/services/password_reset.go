@@ -0,0 +1,86 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordReset issues a new reset token for the user and returns it in
+// plain form for the email. Earlier unused tokens stay valid until they expire.
+func CreatePasswordReset(db *gorm.DB, user *models.User) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Duration(config.AppConfig.PasswordResetTTL) * time.Minute),
+	}
+	if err := db.Create(&reset).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword consumes a reset token and sets the new password, which must
+// satisfy the password policy. All other reset tokens and every session of the
+// user are invalidated.
+func ResetPassword(db *gorm.DB, token, newPassword string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).First(&reset).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		// Claim the token; a concurrent reset with the same token loses here
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.Where("id = ? AND is_active = ? AND email_verified = ?", reset.UserID, true, true).First(&user).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		// A policy violation rolls back the claim so the link can be retried
+		if err := ChangePassword(tx, &user, newPassword); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"failed_logins":  0,
+			"last_failed_at": nil,
+			"locked_until":   nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		return RevokeAllUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
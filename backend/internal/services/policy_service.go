@@ -0,0 +1,166 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/policy"
+	"admin-dashboard/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PolicySubject describes a user for the policy engine: their effective
+// permissions and the highest rank among the roles they hold.
+func PolicySubject(db *gorm.DB, user *models.User) (policy.Subject, error) {
+	subject := policy.Subject{ID: user.ID}
+
+	roleIDs, err := utils.GetUserRoleIDs(db, user)
+	if err != nil {
+		return subject, err
+	}
+	var ranks []int
+	if err := db.Model(&models.Role{}).Where("id IN ?", roleIDs).Pluck("rank", &ranks).Error; err != nil {
+		return subject, err
+	}
+	for i, rank := range ranks {
+		if i == 0 || rank > subject.Rank {
+			subject.Rank = rank
+		}
+	}
+
+	subject.Permissions, err = utils.GetUserPermissions(db, user.ID)
+	return subject, err
+}
+
+// RoleGrant describes what giving someone the roles roleIDs hands out: the
+// roles' ranks and every permission they carry, inherited ones included.
+func RoleGrant(db *gorm.DB, roleIDs []uuid.UUID) (ranks []int, permissions []string, err error) {
+	if len(roleIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	var roles []models.Role
+	if err := db.Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		ranks = append(ranks, role.Rank)
+		names, err := utils.GetRolePermissionNames(db, role.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				permissions = append(permissions, name)
+			}
+		}
+	}
+	return ranks, permissions, nil
+}
+
+// PermissionNames returns the names of the given permissions.
+func PermissionNames(db *gorm.DB, ids []uuid.UUID) ([]string, error) {
+	var names []string
+	if len(ids) == 0 {
+		return names, nil
+	}
+	err := db.Model(&models.Permission{}).Where("id IN ?", ids).Pluck("name", &names).Error
+	return names, err
+}
+
+// UserPolicyRequest builds the request for performing action on target (nil
+// when creating a user) in a way that gives them the roles in newRoleIDs and the
+// permissions in newPermissions. Roles and permissions the target already
+// has don't count as handed out.
+func UserPolicyRequest(db *gorm.DB, action string, target *models.User, newRoleIDs []uuid.UUID, newPermissions []string) (policy.Request, error) {
+	req := policy.Request{Action: action}
+
+	held := map[string]bool{}
+	heldRoles := map[uuid.UUID]bool{}
+	if target != nil {
+		subject, err := PolicySubject(db, target)
+		if err != nil {
+			return req, err
+		}
+		req.TargetUser = &subject
+		for _, perm := range subject.Permissions {
+			held[perm] = true
+		}
+		roleIDs, err := utils.GetUserRoleIDs(db, target)
+		if err != nil {
+			return req, err
+		}
+		for _, roleID := range roleIDs {
+			heldRoles[roleID] = true
+		}
+	}
+
+	var added []uuid.UUID
+	for _, roleID := range newRoleIDs {
+		if !heldRoles[roleID] {
+			added = append(added, roleID)
+		}
+	}
+	ranks, permissions, err := RoleGrant(db, added)
+	if err != nil {
+		return req, err
+	}
+	req.GrantedRanks = ranks
+
+	for _, perm := range append(permissions, newPermissions...) {
+		if !held[perm] {
+			held[perm] = true
+			req.GrantedPermissions = append(req.GrantedPermissions, perm)
+		}
+	}
+	return req, nil
+}
+
+// RolePolicyRequest builds the request for performing action on role (nil
+// when creating one) in a way that sets its rank to newRank, if not nil, and adds
+// the permissions in newPermissions. Permissions the role already has don't
+// count as handed out.
+func RolePolicyRequest(db *gorm.DB, action string, role *models.Role, newRank *int, newPermissions []string) (policy.Request, error) {
+	req := policy.Request{Action: action}
+
+	held := map[string]bool{}
+	if role != nil {
+		req.TargetRole = &policy.RoleSubject{ID: role.ID, Rank: role.Rank}
+		names, err := utils.GetRolePermissionNames(db, role.ID)
+		if err != nil {
+			return req, err
+		}
+		for _, name := range names {
+			held[name] = true
+		}
+	}
+	if newRank != nil {
+		req.GrantedRanks = []int{*newRank}
+	}
+	for _, perm := range newPermissions {
+		if !held[perm] {
+			held[perm] = true
+			req.GrantedPermissions = append(req.GrantedPermissions, perm)
+		}
+	}
+	return req, nil
+}
+
+// ScopeSubject limits subject to the scopes of the API key it is acting with:
+// a key can only do what its owner could with the key's permissions alone.
+func ScopeSubject(subject policy.Subject, scopes []string) policy.Subject {
+	inScope := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		inScope[scope] = true
+	}
+	var permissions []string
+	for _, perm := range subject.Permissions {
+		if inScope[perm] {
+			permissions = append(permissions, perm)
+		}
+	}
+	subject.Permissions = permissions
+	return subject
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"admin-dashboard/internal/policy"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestScopeSubject(t *testing.T) {
+	subject := policy.Subject{ID: uuid.New(), Rank: 50, Permissions: []string{"USER_READ", "USER_UPDATE", "ROLE_MANAGE"}}
+
+	scoped := ScopeSubject(subject, []string{"USER_READ", "CHAT_ACCESS"})
+	if !reflect.DeepEqual(scoped.Permissions, []string{"USER_READ"}) {
+		t.Errorf("permissions = %v, want only USER_READ", scoped.Permissions)
+	}
+	if scoped.ID != subject.ID || scoped.Rank != subject.Rank {
+		t.Errorf("scoping changed the subject: %+v", scoped)
+	}
+
+	// A key with no scopes carries no permissions, rather than all of them
+	if scoped := ScopeSubject(subject, nil); len(scoped.Permissions) != 0 {
+		t.Errorf("permissions with no scopes = %v", scoped.Permissions)
+	}
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleNameTaken      = errors.New("a role with that name already exists")
+	ErrRoleNameRequired   = errors.New("role name is required")
+	ErrRoleBuiltIn        = errors.New("built-in roles can't be renamed or deleted")
+	ErrRoleInUse          = errors.New("role still has users; choose a role to move them to")
+	ErrRoleReassignTarget = errors.New("users must be moved to a different, existing role")
+	ErrPermissionNotFound = errors.New("one or more permissions not found")
+	ErrParentRoleNotFound = errors.New("parent role not found")
+	ErrRoleCycle          = errors.New("a role can't inherit from itself or its descendants")
+)
+
+// SourcedPermission is a permission a role has, with the role it comes from:
+// the role itself, or the nearest ancestor that has it.
+type SourcedPermission struct {
+	models.Permission
+	SourceRoleID   uuid.UUID `json:"source_role_id"`
+	SourceRoleName string    `json:"source_role_name"`
+	Inherited      bool      `json:"inherited"`
+}
+
+// CreateRole creates a role with the given permissions, inheriting from
+// parentID if it isn't nil.
+func CreateRole(db *gorm.DB, name, description string, rank int, parentID *uuid.UUID, permissionIDs []uuid.UUID) (*models.Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrRoleNameRequired
+	}
+
+	role := models.Role{Name: name, Description: description, Rank: rank, ParentID: parentID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := roleNameAvailable(tx, name, uuid.Nil); err != nil {
+			return err
+		}
+		if parentID != nil {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("id = ?", *parentID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrParentRoleNotFound
+			}
+		}
+		permissions, err := findPermissions(tx, permissionIDs)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		if len(permissions) > 0 {
+			return tx.Model(&role).Association("Permissions").Replace(permissions)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.Preload("Permissions").First(&role, role.ID)
+	return &role, nil
+}
+
+// UpdateRole changes a role's name, description and/or rank. Nil fields are
+// left alone.
+func UpdateRole(db *gorm.DB, roleID uuid.UUID, name, description *string, rank *int) (*models.Role, error) {
+	var role models.Role
+	if err := db.Where("id = ?", roleID).First(&role).Error; err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	updates := map[string]interface{}{}
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return nil, ErrRoleNameRequired
+		}
+		if trimmed != role.Name {
+			if role.BuiltIn {
+				return nil, ErrRoleBuiltIn
+			}
+			if err := roleNameAvailable(db, trimmed, role.ID); err != nil {
+				return nil, err
+			}
+			updates["name"] = trimmed
+		}
+	}
+	if description != nil {
+		updates["description"] = *description
+	}
+	if rank != nil {
+		updates["rank"] = *rank
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(&role).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	db.Preload("Permissions").First(&role, role.ID)
+	return &role, nil
+}
+
+// SetRoleParent makes a role inherit from parentID, or from nothing when it is
+// nil. Parents that would make the role its own ancestor are refused.
+func SetRoleParent(db *gorm.DB, roleID uuid.UUID, parentID *uuid.UUID) (*models.Role, error) {
+	var role models.Role
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", roleID).First(&role).Error; err != nil {
+			return ErrRoleNotFound
+		}
+		if parentID != nil {
+			if err := checkRoleCycle(tx, role.ID, *parentID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&role).Update("parent_id", nullableID(parentID)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.Preload("Parent").Preload("Permissions").First(&role, role.ID)
+	return &role, nil
+}
+
+// RolePermissionSources lists every permission a role has, directly or
+// through its ancestors, labelled with where it comes from. A permission the
+// role has directly is always shown as direct.
+func RolePermissionSources(db *gorm.DB, roleID uuid.UUID) ([]SourcedPermission, error) {
+	chain, err := utils.RoleAncestors(db, roleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	ids := make([]uuid.UUID, len(chain))
+	for i, role := range chain {
+		ids[i] = role.ID
+	}
+	var rolePermissions []models.RolePermission
+	if err := db.Preload("Permission").Where("role_id IN ?", ids).Find(&rolePermissions).Error; err != nil {
+		return nil, err
+	}
+	byRole := make(map[uuid.UUID][]models.Permission)
+	for _, rp := range rolePermissions {
+		byRole[rp.RoleID] = append(byRole[rp.RoleID], rp.Permission)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	sourced := []SourcedPermission{}
+	for i, role := range chain {
+		for _, perm := range byRole[role.ID] {
+			if seen[perm.ID] {
+				continue
+			}
+			seen[perm.ID] = true
+			sourced = append(sourced, SourcedPermission{
+				Permission:     perm,
+				SourceRoleID:   role.ID,
+				SourceRoleName: role.Name,
+				Inherited:      i > 0,
+			})
+		}
+	}
+	return sourced, nil
+}
+
+// DeleteRole deletes a role. The users holding it, as their primary role or
+// otherwise, and its unrevoked invitations are moved to reassignTo first;
+// reassignTo may be nil only when no user holds the role, and then its
+// invitations are deleted. Returns how many users were moved.
+func DeleteRole(db *gorm.DB, roleID uuid.UUID, reassignTo *uuid.UUID) (int64, error) {
+	var moved int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("id = ?", roleID).First(&role).Error; err != nil {
+			return ErrRoleNotFound
+		}
+		if role.BuiltIn {
+			return ErrRoleBuiltIn
+		}
+
+		// Soft-deleted users count too: their rows still reference the role
+		var userCount int64
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("role_id = ? OR id IN (SELECT user_id FROM user_roles WHERE role_id = ?)", role.ID, role.ID).
+			Count(&userCount).Error; err != nil {
+			return err
+		}
+
+		if reassignTo == nil {
+			if userCount > 0 {
+				return ErrRoleInUse
+			}
+		} else {
+			if *reassignTo == role.ID {
+				return ErrRoleReassignTarget
+			}
+			var target models.Role
+			if err := tx.Where("id = ?", *reassignTo).First(&target).Error; err != nil {
+				return ErrRoleReassignTarget
+			}
+
+			if err := tx.Exec(`INSERT INTO user_roles (user_id, role_id)
+				SELECT user_id, ? FROM user_roles WHERE role_id = ?
+				ON CONFLICT DO NOTHING`, target.ID, role.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.User{}).Where("role_id = ?", role.ID).Update("role_id", target.ID).Error; err != nil {
+				return err
+			}
+			moved = userCount
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&userRole{}).Error; err != nil {
+			return err
+		}
+
+		// Unrevoked invitations follow the users; the rest can't be redeemed and go
+		if reassignTo != nil {
+			if err := tx.Model(&models.Invitation{}).
+				Where("role_id = ? AND revoked_at IS NULL", role.ID).
+				Update("role_id", *reassignTo).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.Invitation{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		// Children inherit from the deleted role's parent instead, so they
+		// keep everything but the deleted role's own permissions
+		if err := tx.Model(&models.Role{}).Where("parent_id = ?", role.ID).Update("parent_id", nullableID(role.ParentID)).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	return moved, err
+}
+
+// checkRoleCycle walks up from parentID and fails if it reaches roleID. The
+// roles on the way are locked, so two concurrent changes can't close a loop
+// between them.
+func checkRoleCycle(tx *gorm.DB, roleID, parentID uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool)
+	for current := &parentID; current != nil; {
+		if *current == roleID || seen[*current] {
+			return ErrRoleCycle
+		}
+		seen[*current] = true
+
+		var ancestor models.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "parent_id").Where("id = ?", *current).First(&ancestor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && *current == parentID {
+				return ErrParentRoleNotFound
+			}
+			return err
+		}
+		current = ancestor.ParentID
+	}
+	return nil
+}
+
+// nullableID turns a nil ID into a plain nil, which is written as NULL.
+func nullableID(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func roleNameAvailable(db *gorm.DB, name string, exceptID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.Role{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, exceptID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleNameTaken
+	}
+	return nil
+}
+
+func findPermissions(db *gorm.DB, ids []uuid.UUID) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(ids) == 0 {
+		return permissions, nil
+	}
+	if err := db.Where("id IN ?", ids).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	if len(permissions) != len(uniqueIDs(ids)) {
+		return nil, ErrPermissionNotFound
+	}
+	return permissions, nil
+}
+
+func uniqueIDs(ids []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
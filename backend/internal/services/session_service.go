@@ -0,0 +1,239 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"admin-dashboard/internal/utils"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionInactive     = errors.New("session expired or revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// sessionTouchInterval limits how often last_seen_at is written for a busy session.
+const sessionTouchInterval = time.Minute
+
+// SessionMeta describes the client a session is used from.
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenPair is the access/refresh token pair handed to a client after login or refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// CreateSession starts a new refresh token family for the user and issues its first token pair.
+func CreateSession(db *gorm.DB, user *models.User, meta SessionMeta) (*TokenPair, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(secret),
+		Device:           utils.DescribeUserAgent(meta.UserAgent),
+		UserAgent:        meta.UserAgent,
+		IP:               meta.IP,
+		LastSeenAt:       time.Now(),
+		ExpiresAt:        time.Now().Add(time.Duration(config.AppConfig.JWTRefreshTTLHours) * time.Hour),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(user, &session, secret)
+}
+
+// RefreshSession rotates the refresh token of a session and issues a new token pair.
+// Reusing an already rotated refresh token revokes the whole session; any other
+// wrong secret is just invalid, so knowing a session ID isn't enough to end it.
+func RefreshSession(db *gorm.DB, refreshToken string, meta SessionMeta) (*TokenPair, *models.User, error) {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if !session.IsActive() {
+		return nil, nil, ErrSessionInactive
+	}
+
+	currentHash := utils.HashToken(secret)
+	if subtle.ConstantTimeCompare([]byte(currentHash), []byte(session.RefreshTokenHash)) != 1 {
+		var retired int64
+		if err := db.Model(&models.RetiredRefreshToken{}).Where("session_id = ? AND token_hash = ?", session.ID, currentHash).Count(&retired).Error; err != nil {
+			return nil, nil, err
+		}
+		if retired == 0 {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		RevokeSession(db, session.ID)
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	var user models.User
+	if err := db.Preload("Role").Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+		RevokeSession(db, session.ID)
+		return nil, nil, ErrSessionInactive
+	}
+
+	newSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only rotate if nobody else rotated this token in the meantime
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, currentHash).
+			Updates(map[string]interface{}{
+				"refresh_token_hash": utils.HashToken(newSecret),
+				"ip":                 meta.IP,
+				"last_seen_at":       time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		return tx.Create(&models.RetiredRefreshToken{SessionID: session.ID, TokenHash: currentHash}).Error
+	})
+	if err == ErrRefreshTokenReused {
+		RevokeSession(db, session.ID)
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := issueTokenPair(&user, &session, newSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// RevokeSession marks a session as revoked. Access tokens bound to it stop working immediately.
+func RevokeSession(db *gorm.DB, sessionID uuid.UUID) error {
+	return db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchSession records that the session was just used from the given IP. Writes
+// are skipped while the last one is recent.
+func TouchSession(db *gorm.DB, sessionID, ip string) error {
+	return db.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", sessionID, time.Now().Add(-sessionTouchInterval)).
+		Updates(map[string]interface{}{
+			"ip":           ip,
+			"last_seen_at": time.Now(),
+		}).Error
+}
+
+// ListActiveSessions returns the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func ListActiveSessions(db *gorm.DB, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// SessionHandle identifies a session to anyone but its owner. Refresh tokens
+// start with the session ID, so that is only shown to the owner.
+func SessionHandle(sessionID uuid.UUID) string {
+	return utils.HashToken(sessionID.String())
+}
+
+// FindSessionByHandle returns the ID of the user's active session with the given handle.
+func FindSessionByHandle(db *gorm.DB, userID uuid.UUID, handle string) (uuid.UUID, error) {
+	sessions, err := ListActiveSessions(db, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, session := range sessions {
+		if subtle.ConstantTimeCompare([]byte(SessionHandle(session.ID)), []byte(handle)) == 1 {
+			return session.ID, nil
+		}
+	}
+	return uuid.Nil, ErrSessionNotFound
+}
+
+// RevokeUserSession revokes one session, making sure it belongs to the user.
+func RevokeUserSession(db *gorm.DB, userID, sessionID uuid.UUID) error {
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of the user except the one given,
+// e.g. after a password change made from that session.
+func RevokeOtherSessions(db *gorm.DB, userID uuid.UUID, keepSessionID string) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsSessionActive reports whether the session exists, belongs to the user and is neither revoked nor expired.
+func IsSessionActive(db *gorm.DB, sessionID, userID string) bool {
+	var session models.Session
+	if err := db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return false
+	}
+	return session.IsActive()
+}
+
+func issueTokenPair(user *models.User, session *models.Session, secret string) (*TokenPair, error) {
+	accessToken, expiresAt, err := utils.GenerateJWT(user.ID.String(), user.Username, user.RoleID.String(), session.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: session.ID.String() + "." + secret,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// splitRefreshToken splits a "<session id>.<secret>" refresh token into its parts.
+func splitRefreshToken(refreshToken string) (uuid.UUID, string, bool) {
+	parts := strings.SplitN(refreshToken, ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return uuid.Nil, "", false
+	}
+	sessionID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return sessionID, parts[1], true
+}
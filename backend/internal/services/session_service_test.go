@@ -0,0 +1,110 @@
+package services
+
+import (
+	"admin-dashboard/internal/config"
+	"admin-dashboard/internal/models"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newSession logs a fresh user in and returns their first token pair.
+func newSession(t *testing.T, db *gorm.DB) *TokenPair {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWTSecret: "secret", JWTAccessTTLMinutes: 15, JWTRefreshTTLHours: 1}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	role := models.Role{Name: uniqueName("session")}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{FullName: "Jane", Username: uniqueName("jane"), PasswordHash: "x", RoleID: role.ID, IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := CreateSession(db, &user, SessionMeta{})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return pair
+}
+
+func sessionIsActive(t *testing.T, db *gorm.DB, refreshToken string) bool {
+	t.Helper()
+	var session models.Session
+	db.Where("id = ?", strings.SplitN(refreshToken, ".", 2)[0]).First(&session)
+	return session.IsActive()
+}
+
+func TestRefreshSessionRotates(t *testing.T) {
+	db := testDB(t)
+	pair := newSession(t, db)
+
+	rotated, _, err := RefreshSession(db, pair.RefreshToken, SessionMeta{})
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, _, err := RefreshSession(db, rotated.RefreshToken, SessionMeta{}); err != nil {
+		t.Errorf("refreshing with the rotated token: %v", err)
+	}
+}
+
+func TestRefreshSessionRevokesOnReuse(t *testing.T) {
+	db := testDB(t)
+	pair := newSession(t, db)
+
+	if _, _, err := RefreshSession(db, pair.RefreshToken, SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RefreshSession(db, pair.RefreshToken, SessionMeta{}); err != ErrRefreshTokenReused {
+		t.Fatalf("reusing a rotated token = %v, want ErrRefreshTokenReused", err)
+	}
+	if sessionIsActive(t, db, pair.RefreshToken) {
+		t.Error("session survived refresh token reuse")
+	}
+}
+
+func TestRefreshSessionIgnoresUnknownSecret(t *testing.T) {
+	db := testDB(t)
+	pair := newSession(t, db)
+
+	// Someone who only knows the session ID
+	sessionID := strings.SplitN(pair.RefreshToken, ".", 2)[0]
+	if _, _, err := RefreshSession(db, sessionID+".guessed", SessionMeta{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("unknown secret = %v, want ErrInvalidRefreshToken", err)
+	}
+	if !sessionIsActive(t, db, pair.RefreshToken) {
+		t.Fatal("a guessed secret revoked the session")
+	}
+	if _, _, err := RefreshSession(db, pair.RefreshToken, SessionMeta{}); err != nil {
+		t.Errorf("refreshing with the real token afterwards: %v", err)
+	}
+}
+
+func TestFindSessionByHandle(t *testing.T) {
+	db := testDB(t)
+	pair := newSession(t, db)
+
+	var session models.Session
+	if err := db.Where("id = ?", strings.SplitN(pair.RefreshToken, ".", 2)[0]).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	handle := SessionHandle(session.ID)
+	if strings.Contains(handle, session.ID.String()) {
+		t.Fatal("the handle gives away the session ID")
+	}
+
+	found, err := FindSessionByHandle(db, session.UserID, handle)
+	if err != nil || found != session.ID {
+		t.Fatalf("FindSessionByHandle = %s, %v; want %s", found, err, session.ID)
+	}
+	if _, err := FindSessionByHandle(db, session.UserID, session.ID.String()); err != ErrSessionNotFound {
+		t.Errorf("lookup by raw session ID = %v, want ErrSessionNotFound", err)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeToken adds an access token to the denylist until it expires.
+func RevokeToken(db *gorm.DB, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	entry := models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error
+}
+
+// IsTokenRevoked reports whether the access token with the given jti is on the denylist.
+func IsTokenRevoked(db *gorm.DB, jti string) bool {
+	var count int64
+	if err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		// Fail closed: if we can't tell, treat the token as revoked
+		return true
+	}
+	return count > 0
+}
+
+// RevokeAllUserTokens revokes every session of a user, which invalidates all of
+// their access and refresh tokens.
+func RevokeAllUserTokens(db *gorm.DB, userID uuid.UUID) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PruneRevokedTokens periodically deletes denylist entries whose tokens have expired.
+func PruneRevokedTokens(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result := db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+		if result.Error != nil {
+			log.Printf("Failed to prune revoked tokens: %v", result.Error)
+			continue
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Pruned %d expired revoked tokens", result.RowsAffected)
+		}
+	}
+}
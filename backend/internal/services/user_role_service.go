@@ -0,0 +1,152 @@
+package services
+
+import (
+	"admin-dashboard/internal/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRolesRequired        = errors.New("at least one role is required")
+	ErrInvalidRoles         = errors.New("one or more roles not found")
+	ErrPrimaryRoleNotHeld   = errors.New("primary role must be one of the user's roles")
+	ErrInvalidOverride      = errors.New("override effect must be grant or deny")
+	ErrDuplicateOverride    = errors.New("a permission can only be overridden once")
+	ErrOverridePermNotFound = errors.New("one or more permissions not found")
+)
+
+// PermissionOverride is one requested grant or deny.
+type PermissionOverride struct {
+	PermissionID uuid.UUID `json:"permission_id" binding:"required"`
+	Effect       string    `json:"effect" binding:"required"`
+}
+
+// SetUserRoles replaces the set of roles a user holds. primaryRoleID picks the
+// primary role (users.role_id) from the set; when nil the current one is kept
+// if it is still in the set, otherwise the first of roleIDs is used.
+func SetUserRoles(db *gorm.DB, user *models.User, roleIDs []uuid.UUID, primaryRoleID *uuid.UUID) error {
+	if len(roleIDs) == 0 {
+		return ErrRolesRequired
+	}
+
+	var roles []models.Role
+	if err := db.Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+		return err
+	}
+	if len(roles) != len(uniqueIDs(roleIDs)) {
+		return ErrInvalidRoles
+	}
+
+	held := make(map[uuid.UUID]bool, len(roles))
+	for _, role := range roles {
+		held[role.ID] = true
+	}
+	primary := user.RoleID
+	if primaryRoleID != nil {
+		primary = *primaryRoleID
+	} else if !held[primary] {
+		primary = roleIDs[0]
+	}
+	if !held[primary] {
+		return ErrPrimaryRoleNotHeld
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if primary != user.RoleID {
+			if err := tx.Model(user).Update("role_id", primary).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_id = ? AND role_id NOT IN ?", user.ID, roleIDs).Delete(&userRole{}).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := addUserRole(tx, user.ID, role.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplacePrimaryRole swaps a user's primary role for another, in the role set
+// as well, leaving the user's other roles alone.
+func ReplacePrimaryRole(db *gorm.DB, user *models.User, roleID uuid.UUID) error {
+	oldRoleID := user.RoleID
+	if roleID == oldRoleID {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role_id", roleID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND role_id = ?", user.ID, oldRoleID).Delete(&userRole{}).Error; err != nil {
+			return err
+		}
+		return addUserRole(tx, user.ID, roleID)
+	})
+}
+
+// ListPermissionOverrides returns the user's overrides with their permissions.
+func ListPermissionOverrides(db *gorm.DB, userID uuid.UUID) ([]models.UserPermissionOverride, error) {
+	var overrides []models.UserPermissionOverride
+	err := db.Preload("Permission").Where("user_id = ?", userID).Order("created_at").Find(&overrides).Error
+	return overrides, err
+}
+
+// SetPermissionOverrides replaces all of a user's permission overrides.
+func SetPermissionOverrides(db *gorm.DB, userID uuid.UUID, overrides []PermissionOverride) error {
+	permissionIDs := make([]uuid.UUID, len(overrides))
+	for i, override := range overrides {
+		if override.Effect != models.OverrideGrant && override.Effect != models.OverrideDeny {
+			return ErrInvalidOverride
+		}
+		permissionIDs[i] = override.PermissionID
+	}
+	if len(uniqueIDs(permissionIDs)) != len(permissionIDs) {
+		return ErrDuplicateOverride
+	}
+	if len(permissionIDs) > 0 {
+		var count int64
+		if err := db.Model(&models.Permission{}).Where("id IN ?", permissionIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(permissionIDs) {
+			return ErrOverridePermNotFound
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserPermissionOverride{}).Error; err != nil {
+			return err
+		}
+		for _, override := range overrides {
+			record := models.UserPermissionOverride{
+				UserID:       userID,
+				PermissionID: override.PermissionID,
+				Effect:       override.Effect,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// userRole is a row of the user_roles join table behind models.User.Roles.
+type userRole struct {
+	UserID uuid.UUID
+	RoleID uuid.UUID
+}
+
+func (userRole) TableName() string {
+	return "user_roles"
+}
+
+func addUserRole(tx *gorm.DB, userID, roleID uuid.UUID) error {
+	return tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", userID, roleID).Error
+}
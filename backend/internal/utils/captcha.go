@@ -8,52 +8,70 @@ import (
 	"strconv"
 )
 
+// captchaCharset leaves out characters that are easy to confuse (0/O, 1/I).
+const captchaCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
 type CaptchaChallenge struct {
 	ID       string `json:"id"`
+	Type     string `json:"type"`
 	Question string `json:"question"`
 	Answer   string `json:"-"` // Not sent to client (can be int as string or string code)
+	Image    []byte `json:"-"` // PNG for image captchas, served separately
 }
 
 func GenerateMathCaptcha() CaptchaChallenge {
 	// Generate random numbers for simple math problem
 	num1, _ := rand.Int(rand.Reader, big.NewInt(20))
 	num2, _ := rand.Int(rand.Reader, big.NewInt(20))
-	
+
 	a := int(num1.Int64()) + 1
 	b := int(num2.Int64()) + 1
 	answer := a + b
 
-	// Generate a unique ID for this captcha
-	idBytes := make([]byte, 16)
-	rand.Read(idBytes)
-	id := base64.URLEncoding.EncodeToString(idBytes)
-
 	return CaptchaChallenge{
-		ID:       id,
+		ID:       newCaptchaID(),
+		Type:     CaptchaTypeMath,
 		Question: fmt.Sprintf("What is %d + %d?", a, b),
 		Answer:   strconv.Itoa(answer),
 	}
 }
 
 func GenerateStringCaptcha() CaptchaChallenge {
-	// Generate random 5-character string
-	const chars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
-	length := 5
-	bytes := make([]byte, length)
-	for i := range bytes {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
-		bytes[i] = chars[num.Int64()]
+	captchaStr := randomCaptchaCode(5)
+
+	return CaptchaChallenge{
+		ID:       newCaptchaID(),
+		Type:     CaptchaTypeText,
+		Question: "Enter the following code: " + captchaStr,
+		Answer:   captchaStr,
 	}
-	captchaStr := string(bytes)
+}
 
-	// Generate a unique ID
-	idBytes := make([]byte, 16)
-	rand.Read(idBytes)
-	id := base64.URLEncoding.EncodeToString(idBytes)
+// GenerateImageCaptcha returns a challenge whose code is only shown in a
+// distorted PNG, not in the question text.
+func GenerateImageCaptcha() CaptchaChallenge {
+	captchaStr := randomCaptchaCode(5)
 
 	return CaptchaChallenge{
-		ID:       id,
-		Question: "Enter the following code: " + captchaStr,
+		ID:       newCaptchaID(),
+		Type:     CaptchaTypeImage,
+		Question: "Enter the characters shown in the image",
 		Answer:   captchaStr,
+		Image:    RenderCaptchaImage(captchaStr),
+	}
+}
+
+func randomCaptchaCode(length int) string {
+	bytes := make([]byte, length)
+	for i := range bytes {
+		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(captchaCharset))))
+		bytes[i] = captchaCharset[num.Int64()]
 	}
+	return string(bytes)
+}
+
+func newCaptchaID() string {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+	return base64.URLEncoding.EncodeToString(idBytes)
 }
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	mrand "math/rand"
+)
+
+const (
+	captchaImageWidth  = 200
+	captchaImageHeight = 70
+	captchaGlyphScale  = 5
+)
+
+// captchaGlyphs is a 5x7 bitmap font covering captchaCharset, so the image
+// captcha needs no font files or third-party rendering libraries.
+var captchaGlyphs = map[byte][7]string{
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"####.", "....#", "....#", ".###.", "....#", "....#", "####."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+}
+
+// RenderCaptchaImage draws text as a distorted PNG: every character gets its
+// own rotation, offset and colour, the whole picture is warped by a sine
+// wave, and noise lines and dots are scattered over it.
+func RenderCaptchaImage(text string) []byte {
+	rng := mrand.New(mrand.NewSource(mrand.Int63()))
+
+	glyphs := image.NewRGBA(image.Rect(0, 0, captchaImageWidth, captchaImageHeight))
+	background := color.RGBA{uint8(235 + rng.Intn(20)), uint8(235 + rng.Intn(20)), uint8(235 + rng.Intn(20)), 255}
+	fill(glyphs, background)
+
+	glyphW, glyphH := 5*captchaGlyphScale, 7*captchaGlyphScale
+	step := (captchaImageWidth - 20) / maxInt(len(text), 1)
+	for i := 0; i < len(text); i++ {
+		glyph, ok := captchaGlyphs[text[i]]
+		if !ok {
+			continue
+		}
+
+		ink := randomInk(rng)
+		angle := (rng.Float64() - 0.5) * 0.5
+		sin, cos := math.Sin(angle), math.Cos(angle)
+		cx := float64(10 + i*step + step/2 + rng.Intn(7) - 3)
+		cy := float64(captchaImageHeight/2 + rng.Intn(11) - 5)
+
+		// Walk the rotated bounding box and map each pixel back into the glyph
+		radius := int(math.Hypot(float64(glyphW), float64(glyphH))/2) + 1
+		for y := int(cy) - radius; y <= int(cy)+radius; y++ {
+			for x := int(cx) - radius; x <= int(cx)+radius; x++ {
+				dx, dy := float64(x)-cx, float64(y)-cy
+				gx := int((dx*cos+dy*sin)+float64(glyphW)/2) / captchaGlyphScale
+				gy := int((-dx*sin+dy*cos)+float64(glyphH)/2) / captchaGlyphScale
+				if gx < 0 || gx >= 5 || gy < 0 || gy >= 7 || glyph[gy][gx] != '#' {
+					continue
+				}
+				glyphs.Set(x, y, ink)
+			}
+		}
+	}
+
+	// Warp horizontally and vertically with independent sine waves
+	img := image.NewRGBA(glyphs.Bounds())
+	ampX, ampY := 1.5+rng.Float64()*1.5, 2+rng.Float64()*3
+	periodX, periodY := 50+rng.Float64()*30, 60+rng.Float64()*40
+	phaseX, phaseY := rng.Float64()*2*math.Pi, rng.Float64()*2*math.Pi
+	for y := 0; y < captchaImageHeight; y++ {
+		for x := 0; x < captchaImageWidth; x++ {
+			sx := x + int(ampX*math.Sin(float64(y)/periodX*2*math.Pi+phaseX))
+			sy := y + int(ampY*math.Sin(float64(x)/periodY*2*math.Pi+phaseY))
+			if sx < 0 || sx >= captchaImageWidth || sy < 0 || sy >= captchaImageHeight {
+				img.Set(x, y, background)
+				continue
+			}
+			img.Set(x, y, glyphs.At(sx, sy))
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		drawLine(img,
+			rng.Intn(captchaImageWidth), rng.Intn(captchaImageHeight),
+			rng.Intn(captchaImageWidth), rng.Intn(captchaImageHeight),
+			randomInk(rng))
+	}
+	for i := 0; i < captchaImageWidth*captchaImageHeight/25; i++ {
+		img.Set(rng.Intn(captchaImageWidth), rng.Intn(captchaImageHeight), randomInk(rng))
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func randomInk(rng *mrand.Rand) color.RGBA {
+	return color.RGBA{uint8(rng.Intn(140)), uint8(rng.Intn(140)), uint8(rng.Intn(140)), 255}
+}
+
+func fill(img *image.RGBA, c color.RGBA) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// drawLine draws a line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := absInt(x1-x0), -absInt(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,49 @@
+package utils
+
+import "fmt"
+
+const (
+	CaptchaTypeMath  = "math"
+	CaptchaTypeText  = "text"
+	CaptchaTypeImage = "image"
+)
+
+// CaptchaProvider creates captcha challenges. Every provider fills in ID and
+// Answer the same way, so storage and verification don't depend on the type.
+type CaptchaProvider interface {
+	Name() string
+	Generate() CaptchaChallenge
+}
+
+// MathCaptchaProvider asks for the sum of two small numbers.
+type MathCaptchaProvider struct{}
+
+func (MathCaptchaProvider) Name() string               { return CaptchaTypeMath }
+func (MathCaptchaProvider) Generate() CaptchaChallenge { return GenerateMathCaptcha() }
+
+// TextCaptchaProvider asks the user to retype a code shown as plain text. It
+// is the accessible fallback for users who can't read the image captcha.
+type TextCaptchaProvider struct{}
+
+func (TextCaptchaProvider) Name() string               { return CaptchaTypeText }
+func (TextCaptchaProvider) Generate() CaptchaChallenge { return GenerateStringCaptcha() }
+
+// ImageCaptchaProvider shows a code in a server-rendered, distorted PNG.
+type ImageCaptchaProvider struct{}
+
+func (ImageCaptchaProvider) Name() string               { return CaptchaTypeImage }
+func (ImageCaptchaProvider) Generate() CaptchaChallenge { return GenerateImageCaptcha() }
+
+// NewCaptchaProvider returns the provider registered under name.
+func NewCaptchaProvider(name string) (CaptchaProvider, error) {
+	switch name {
+	case CaptchaTypeMath, "":
+		return MathCaptchaProvider{}, nil
+	case CaptchaTypeText:
+		return TextCaptchaProvider{}, nil
+	case CaptchaTypeImage:
+		return ImageCaptchaProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", name)
+	}
+}
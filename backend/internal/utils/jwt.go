@@ -2,36 +2,89 @@ package utils
 
 import (
 	"admin-dashboard/internal/config"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	RoleID   string `json:"role_id"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	RoleID    string `json:"role_id"`
+	SessionID string `json:"sid"`
+	// Actor is set on impersonation tokens and names the admin really acting
+	Actor *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID, username, roleID string) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWTExpirationHours) * time.Hour)
+// Actor identifies who is acting on behalf of the token's user (RFC 8693 "act").
+type Actor struct {
+	UserID   string `json:"sub"`
+	Username string `json:"username"`
+}
+
+func GenerateJWT(userID, username, roleID, sessionID string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWTAccessTTLMinutes) * time.Minute)
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		RoleID:   roleID,
+		UserID:    userID,
+		Username:  username,
+		RoleID:    roleID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWTSecret))
+	tokenString, err := signToken(claims)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// GenerateImpersonationJWT issues a short-lived access token for userID that
+// records actor as the real caller. It lives on the actor's session, so ending
+// that session ends the impersonation too.
+func GenerateImpersonationJWT(userID, username, roleID, sessionID string, actor Actor, ttl time.Duration) (string, time.Time, error) {
+	expirationTime := time.Now().Add(ttl)
+	claims := &Claims{
+		UserID:    userID,
+		Username:  username,
+		RoleID:    roleID,
+		SessionID: sessionID,
+		Actor:     &actor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	tokenString, err := signToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// ParseJWT validates an access token and returns its claims.
+func ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey, jwt.WithValidMethods(validSigningMethods()))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return tokenString, nil
+	return claims, nil
 }
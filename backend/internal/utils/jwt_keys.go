@@ -0,0 +1,322 @@
+package utils
+
+import (
+	"admin-dashboard/internal/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey is one key from the key directory. Private is nil for keys that are
+// only kept around to verify tokens signed before a rotation.
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+type jwtKeySet struct {
+	signing *jwtKey
+	verify  map[string]*jwtKey
+}
+
+var jwtKeys *jwtKeySet
+
+// InitJWTKeys loads signing and verification keys according to config.
+//
+// With JWT_SIGNING_ALG=RS256 or EdDSA, every "<kid>.pem" private key and
+// "<kid>.pub.pem" public key in JWT_KEYS_DIR can verify tokens, and the key
+// named by JWT_ACTIVE_KID (or the last kid in sort order) signs new ones. If
+// no private key exists for the algorithm, one is generated and saved there in
+// debug mode; otherwise startup fails rather than sign with a key nobody
+// provisioned. With HS256, JWT_SECRET is used as before.
+func InitJWTKeys() error {
+	alg := config.AppConfig.JWTSigningAlg
+	if alg == "HS256" {
+		jwtKeys = &jwtKeySet{}
+		return nil
+	}
+
+	if alg != "RS256" && alg != "EdDSA" {
+		return fmt.Errorf("unsupported JWT_SIGNING_ALG %q (use RS256, EdDSA or HS256)", alg)
+	}
+	method := jwt.GetSigningMethod(alg)
+
+	dir := config.AppConfig.JWTKeysDir
+	keys, err := loadJWTKeys(dir)
+	if err != nil {
+		return err
+	}
+
+	var candidates []string
+	for kid, key := range keys {
+		if key.private != nil && key.method.Alg() == alg {
+			candidates = append(candidates, kid)
+		}
+	}
+	sort.Strings(candidates)
+
+	if len(candidates) == 0 {
+		if config.AppConfig.GinMode != "debug" {
+			return fmt.Errorf("no %s private key in JWT_KEYS_DIR %s (keys are only generated with GIN_MODE=debug)", alg, dir)
+		}
+		key, err := generateJWTKey(dir, method)
+		if err != nil {
+			return err
+		}
+		keys[key.kid] = key
+		candidates = append(candidates, key.kid)
+		log.Printf("WARNING: No %s signing key found, generated %s in %s", alg, key.kid, dir)
+	}
+
+	activeKid := config.AppConfig.JWTActiveKid
+	if activeKid == "" {
+		activeKid = candidates[len(candidates)-1]
+	}
+	active, ok := keys[activeKid]
+	if !ok || active.private == nil || active.method.Alg() != alg {
+		return fmt.Errorf("JWT_ACTIVE_KID %q is not a %s private key in %s", activeKid, alg, dir)
+	}
+
+	jwtKeys = &jwtKeySet{signing: active, verify: keys}
+	log.Printf("JWT signing with %s key %s (%d verification keys)", alg, activeKid, len(keys))
+	return nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if jwtKeys == nil {
+		return set
+	}
+
+	kids := make([]string, 0, len(jwtKeys.verify))
+	for kid := range jwtKeys.verify {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := jwtKeys.verify[kid]
+		if jwk, err := NewJWK(key.public, kid, key.method.Alg()); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// NewJWK encodes an RSA or Ed25519 public key as a JWK.
+func NewJWK(public crypto.PublicKey, kid, alg string) (JWK, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: alg,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: alg,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	}
+	return JWK{}, errors.New("unsupported public key type")
+}
+
+// signToken signs claims with the active key, setting the kid header.
+func signToken(claims jwt.Claims) (string, error) {
+	if jwtKeys == nil || jwtKeys.signing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.AppConfig.JWTSecret))
+	}
+
+	token := jwt.NewWithClaims(jwtKeys.signing.method, claims)
+	token.Header["kid"] = jwtKeys.signing.kid
+	return token.SignedString(jwtKeys.signing.private)
+}
+
+// verificationKey is the jwt.Keyfunc for our own tokens. The token's alg must
+// match the key its kid names.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if jwtKeys == nil || jwtKeys.signing == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return []byte(config.AppConfig.JWTSecret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("signing method %s does not match key %q", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// validSigningMethods lists the algorithms ParseJWT accepts: those of every
+// loaded key, so tokens signed before switching JWT_SIGNING_ALG stay valid as
+// long as their key is kept. verificationKey then picks the key by kid.
+func validSigningMethods() []string {
+	if jwtKeys == nil || jwtKeys.signing == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range jwtKeys.verify {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func loadJWTKeys(dir string) (map[string]*jwtKey, error) {
+	keys := make(map[string]*jwtKey)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return keys, nil
+		}
+		return nil, fmt.Errorf("failed to read JWT key directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM data", name)
+		}
+
+		var key *jwtKey
+		if strings.HasSuffix(name, ".pub.pem") {
+			key, err = parsePublicJWTKey(block)
+			if key != nil {
+				key.kid = strings.TrimSuffix(name, ".pub.pem")
+			}
+		} else {
+			key, err = parsePrivateJWTKey(block)
+			if key != nil {
+				key.kid = strings.TrimSuffix(name, ".pem")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		// A private key wins over a public-only file with the same kid
+		if existing, ok := keys[key.kid]; ok && existing.private != nil {
+			continue
+		}
+		keys[key.kid] = key
+	}
+
+	return keys, nil
+}
+
+func parsePrivateJWTKey(block *pem.Block) (*jwtKey, error) {
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch priv := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodRS256, private: priv, public: &priv.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, private: priv, public: priv.Public()}, nil
+	}
+	return nil, errors.New("unsupported private key type (use RSA or Ed25519)")
+}
+
+func parsePublicJWTKey(block *pem.Block) (*jwtKey, error) {
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		return &jwtKey{method: jwt.SigningMethodRS256, public: pub}, nil
+	case ed25519.PublicKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, public: pub}, nil
+	}
+	return nil, errors.New("unsupported public key type (use RSA or Ed25519)")
+}
+
+// generateJWTKey creates a new private key for the method and saves it as <kid>.pem.
+func generateJWTKey(dir string, method jwt.SigningMethod) (*jwtKey, error) {
+	var priv crypto.Signer
+	var err error
+	switch method.Alg() {
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("cannot generate key for %s", method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	// The algorithm keeps kids apart when switching JWT_SIGNING_ALG
+	kid := strings.ToLower(method.Alg()) + "-" + time.Now().UTC().Format("20060102-150405")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create JWT key directory: %w", err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pemData, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save generated JWT key: %w", err)
+	}
+
+	return &jwtKey{kid: kid, method: method, private: priv, public: priv.Public()}, nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"admin-dashboard/internal/config"
+	"strings"
+	"testing"
+)
+
+func useJWTConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	previous, previousKeys := config.AppConfig, jwtKeys
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig, jwtKeys = previous, previousKeys })
+}
+
+func TestInitJWTKeysRequiresKeyOutsideDebugMode(t *testing.T) {
+	dir := t.TempDir()
+	useJWTConfig(t, &config.Config{GinMode: "release", JWTSigningAlg: "EdDSA", JWTKeysDir: dir})
+
+	err := InitJWTKeys()
+	if err == nil || !strings.Contains(err.Error(), "no EdDSA private key") {
+		t.Fatalf("InitJWTKeys = %v, want a missing key error", err)
+	}
+
+	// Debug mode generates one instead
+	config.AppConfig.GinMode = "debug"
+	if err := InitJWTKeys(); err != nil {
+		t.Fatalf("InitJWTKeys in debug mode: %v", err)
+	}
+	config.AppConfig.GinMode = "release"
+	if err := InitJWTKeys(); err != nil {
+		t.Errorf("InitJWTKeys with the generated key: %v", err)
+	}
+}
+
+func TestParseJWTAfterSwitchingAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	useJWTConfig(t, &config.Config{GinMode: "debug", JWTSigningAlg: "RS256", JWTKeysDir: dir, JWTAccessTTLMinutes: 15})
+
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _, err := GenerateJWT("user", "jane", "role", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The RS256 key stays in the directory after the switch
+	config.AppConfig.JWTSigningAlg = "EdDSA"
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	newToken, _, err := GenerateJWT("user", "jane", "role", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"RS256": oldToken, "EdDSA": newToken} {
+		if claims, err := ParseJWT(token); err != nil || claims.Username != "jane" {
+			t.Errorf("ParseJWT(%s token) = %v, %v", name, claims, err)
+		}
+	}
+
+	// HS256 with the secret isn't accepted once keys are in use
+	config.AppConfig.JWTSecret = "secret"
+	saved := jwtKeys
+	jwtKeys = nil
+	hsToken, _, err := GenerateJWT("user", "jane", "role", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtKeys = saved
+	if _, err := ParseJWT(hsToken); err == nil {
+		t.Error("an HS256 token was accepted")
+	}
+}
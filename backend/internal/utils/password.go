@@ -1,13 +1,215 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"admin-dashboard/internal/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher produces self-describing hashes: the algorithm and its
+// parameters are encoded in the hash, so old hashes keep verifying after the
+// configuration changes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify checks a hash this hasher's algorithm produced, whatever its parameters.
+	Verify(password, hash string) bool
+	// Owns reports whether hash uses this hasher's algorithm.
+	Owns(hash string) bool
+	// Current reports whether hash was made with this hasher's current parameters.
+	Current(hash string) bool
+}
+
+// Argon2idHasher hashes with argon2id in the PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2KeyLength)
+	return h.encode(salt, key), nil
+}
+
+func (h Argon2idHasher) Verify(password, hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+func (h Argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h Argon2idHasher) Current(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	return err == nil && params == h && len(key) == argon2KeyLength
+}
+
+func (h Argon2idHasher) String() string {
+	return fmt.Sprintf("argon2id (m=%d KiB, t=%d, p=%d)", h.Memory, h.Time, h.Threads)
+}
+
+func (h Argon2idHasher) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, err
+	}
+	if params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errors.New("invalid argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2 key")
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes with bcrypt at the given cost.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
 	return string(bytes), err
 }
 
-func CheckPasswordHash(password, hash string) bool {
+func (h BcryptHasher) Verify(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func (h BcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == h.Cost
+}
+
+func (h BcryptHasher) String() string {
+	return fmt.Sprintf("bcrypt (cost %d)", h.Cost)
+}
+
+// passwordHasher hashes new passwords. Until InitPasswordHasher runs it uses
+// the default parameters, so tools that skip it still produce valid hashes.
+var passwordHasher PasswordHasher = Argon2idHasher{Memory: 64 * 1024, Time: 3, Threads: 2}
+
+// passwordVerifiers recognise every supported hash format.
+var passwordVerifiers = []PasswordHasher{
+	Argon2idHasher{},
+	BcryptHasher{},
+}
+
+// InitPasswordHasher selects the hasher for new passwords from config and
+// logs how long one hash takes, so the parameters can be tuned to the host.
+func InitPasswordHasher() error {
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if _, err := hasher.Hash("benchmark-password"); err != nil {
+		return fmt.Errorf("password hasher benchmark failed: %w", err)
+	}
+	elapsed := time.Since(start)
+
+	log.Printf("Password hashing: %v takes %v per hash", hasher, elapsed.Round(time.Millisecond))
+	switch {
+	case elapsed > time.Second:
+		log.Printf("WARNING: password hashing is slow; logins will queue up under load. Consider lowering the parameters")
+	case elapsed < 50*time.Millisecond:
+		log.Printf("WARNING: password hashing is fast; consider raising the parameters")
+	}
+
+	passwordHasher = hasher
+	return nil
+}
+
+func newPasswordHasher() (PasswordHasher, error) {
+	cfg := config.AppConfig
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id":
+		if cfg.Argon2MemoryKB < 8*cfg.Argon2Threads || cfg.Argon2Time < 1 || cfg.Argon2Threads < 1 || cfg.Argon2Threads > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters (memory %d KiB, time %d, threads %d)",
+				cfg.Argon2MemoryKB, cfg.Argon2Time, cfg.Argon2Threads)
+		}
+		return Argon2idHasher{
+			Memory:  uint32(cfg.Argon2MemoryKB),
+			Time:    uint32(cfg.Argon2Time),
+			Threads: uint8(cfg.Argon2Threads),
+		}, nil
+	case "bcrypt":
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid BCRYPT_COST %d (use %d-%d)", cfg.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return BcryptHasher{Cost: cfg.BcryptCost}, nil
+	}
+	return nil, fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM %q (use argon2id or bcrypt)", cfg.PasswordHashAlgorithm)
+}
+
+func HashPassword(password string) (string, error) {
+	return passwordHasher.Hash(password)
+}
+
+// CheckPasswordHash verifies a password against a hash in any supported format.
+func CheckPasswordHash(password, hash string) bool {
+	for _, verifier := range passwordVerifiers {
+		if verifier.Owns(hash) {
+			return verifier.Verify(password, hash)
+		}
+	}
+	return false
+}
+
+// PasswordNeedsRehash reports whether hash uses another algorithm or older
+// parameters than new passwords get.
+func PasswordNeedsRehash(hash string) bool {
+	return !passwordHasher.Owns(hash) || !passwordHasher.Current(hash)
+}
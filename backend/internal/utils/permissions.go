@@ -2,19 +2,20 @@ package utils
 
 import (
 	"admin-dashboard/internal/models"
+	"sort"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 func UserHasPermission(db *gorm.DB, userID uuid.UUID, permissionName string) bool {
-	var user models.User
-	if err := db.Preload("Role.Permissions").Where("id = ?", userID).First(&user).Error; err != nil {
+	permissions, err := GetUserPermissions(db, userID)
+	if err != nil {
 		return false
 	}
 
-	for _, perm := range user.Role.Permissions {
-		if perm.Name == permissionName {
+	for _, perm := range permissions {
+		if perm == permissionName {
 			return true
 		}
 	}
@@ -22,16 +23,226 @@ func UserHasPermission(db *gorm.DB, userID uuid.UUID, permissionName string) boo
 	return false
 }
 
+// GetUserPermissions returns the user's effective permissions: everything
+// their roles and grant overrides give them, minus their deny overrides.
 func GetUserPermissions(db *gorm.DB, userID uuid.UUID) ([]string, error) {
 	var user models.User
-	if err := db.Preload("Role.Permissions").Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := db.Select("id", "role_id").Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	byUser, err := GetPermissionsForUsers(db, []models.User{user})
+	if err != nil {
+		return nil, err
+	}
+	return byUser[user.ID], nil
+}
+
+// GetUserRoleIDs returns the IDs of every role the user holds.
+func GetUserRoleIDs(db *gorm.DB, user *models.User) ([]uuid.UUID, error) {
+	byUser, err := userRoleIDs(db, []models.User{*user})
+	if err != nil {
+		return nil, err
+	}
+	return byUser[user.ID], nil
+}
+
+// GetPermissionsForUsers computes the effective permissions of several users
+// at once, keyed by user ID. Only the users' ID and RoleID are used.
+func GetPermissionsForUsers(db *gorm.DB, users []models.User) (map[uuid.UUID][]string, error) {
+	result := make(map[uuid.UUID][]string, len(users))
+	if len(users) == 0 {
+		return result, nil
+	}
+
+	roleIDsByUser, err := userRoleIDs(db, users)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := roleParents(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roles inherit their ancestors' permissions
+	roleSet := make(map[uuid.UUID]bool)
+	for userID, roleIDs := range roleIDsByUser {
+		roleIDsByUser[userID] = withAncestors(roleIDs, parents)
+		for _, roleID := range roleIDsByUser[userID] {
+			roleSet[roleID] = true
+		}
+	}
+	allRoleIDs := make([]uuid.UUID, 0, len(roleSet))
+	for roleID := range roleSet {
+		allRoleIDs = append(allRoleIDs, roleID)
+	}
+
+	var rolePermissions []struct {
+		RoleID uuid.UUID
+		Name   string
+	}
+	if err := db.Table("role_permissions").
+		Select("role_permissions.role_id, permissions.name").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("role_permissions.role_id IN ?", allRoleIDs).
+		Scan(&rolePermissions).Error; err != nil {
+		return nil, err
+	}
+	permissionsByRole := make(map[uuid.UUID][]string)
+	for _, rp := range rolePermissions {
+		permissionsByRole[rp.RoleID] = append(permissionsByRole[rp.RoleID], rp.Name)
+	}
+
+	userIDs := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	var overrides []models.UserPermissionOverride
+	if err := db.Preload("Permission").Where("user_id IN ?", userIDs).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	overridesByUser := make(map[uuid.UUID][]models.UserPermissionOverride)
+	for _, override := range overrides {
+		overridesByUser[override.UserID] = append(overridesByUser[override.UserID], override)
+	}
+
+	for _, user := range users {
+		var fromRoles []string
+		for _, roleID := range roleIDsByUser[user.ID] {
+			fromRoles = append(fromRoles, permissionsByRole[roleID]...)
+		}
+		result[user.ID] = EffectivePermissions(fromRoles, overridesByUser[user.ID])
+	}
+	return result, nil
+}
+
+// GetRolePermissionNames returns the names of the permissions a role has,
+// directly or inherited from its ancestors.
+func GetRolePermissionNames(db *gorm.DB, roleID uuid.UUID) ([]string, error) {
+	parents, err := roleParents(db)
+	if err != nil {
 		return nil, err
 	}
 
-	permissions := make([]string, len(user.Role.Permissions))
-	for i, perm := range user.Role.Permissions {
-		permissions[i] = perm.Name
+	var names []string
+	err = db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id IN ?", withAncestors([]uuid.UUID{roleID}, parents)).
+		Distinct().
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// RoleAncestors returns the role followed by its parent, its parent's parent
+// and so on up to a role without a parent.
+func RoleAncestors(db *gorm.DB, roleID uuid.UUID) ([]models.Role, error) {
+	parents, err := roleParents(db)
+	if err != nil {
+		return nil, err
+	}
+	ids := withAncestors([]uuid.UUID{roleID}, parents)
+
+	var roles []models.Role
+	if err := db.Where("id IN ?", ids).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.Role, len(roles))
+	for _, role := range roles {
+		byID[role.ID] = role
 	}
 
-	return permissions, nil
+	chain := make([]models.Role, 0, len(ids))
+	for _, id := range ids {
+		if role, ok := byID[id]; ok {
+			chain = append(chain, role)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return chain, nil
+}
+
+// EffectivePermissions applies a user's overrides to the permissions their
+// roles give them. Denies take precedence over grants from either source.
+// The result is sorted and free of duplicates.
+func EffectivePermissions(fromRoles []string, overrides []models.UserPermissionOverride) []string {
+	granted := make(map[string]bool, len(fromRoles))
+	for _, name := range fromRoles {
+		granted[name] = true
+	}
+	denied := make(map[string]bool)
+	for _, override := range overrides {
+		switch override.Effect {
+		case models.OverrideGrant:
+			granted[override.Permission.Name] = true
+		case models.OverrideDeny:
+			denied[override.Permission.Name] = true
+		}
+	}
+
+	permissions := make([]string, 0, len(granted))
+	for name := range granted {
+		if !denied[name] {
+			permissions = append(permissions, name)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// roleParents maps every role that has a parent to that parent.
+func roleParents(db *gorm.DB) (map[uuid.UUID]uuid.UUID, error) {
+	var links []struct {
+		ID       uuid.UUID
+		ParentID uuid.UUID
+	}
+	if err := db.Model(&models.Role{}).Select("id, parent_id").Where("parent_id IS NOT NULL").Scan(&links).Error; err != nil {
+		return nil, err
+	}
+	parents := make(map[uuid.UUID]uuid.UUID, len(links))
+	for _, link := range links {
+		parents[link.ID] = link.ParentID
+	}
+	return parents, nil
+}
+
+// withAncestors returns roleIDs followed by their ancestors, each once and
+// nearest first. Writes refuse to create cycles; if one exists anyway, the
+// walk stops where it would repeat a role.
+func withAncestors(roleIDs []uuid.UUID, parents map[uuid.UUID]uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(roleIDs))
+	result := make([]uuid.UUID, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		for id, ok := roleID, true; ok && !seen[id]; id, ok = parents[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// userRoleIDs returns each user's roles: their role set plus, in case it is
+// missing from the set, their primary role.
+func userRoleIDs(db *gorm.DB, users []models.User) (map[uuid.UUID][]uuid.UUID, error) {
+	result := make(map[uuid.UUID][]uuid.UUID, len(users))
+	userIDs := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+		result[user.ID] = []uuid.UUID{user.RoleID}
+	}
+
+	var links []struct {
+		UserID uuid.UUID
+		RoleID uuid.UUID
+	}
+	if err := db.Table("user_roles").Select("user_id, role_id").Where("user_id IN ?", userIDs).Scan(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		if link.RoleID != result[link.UserID][0] {
+			result[link.UserID] = append(result[link.UserID], link.RoleID)
+		}
+	}
+	return result, nil
 }
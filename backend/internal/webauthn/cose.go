@@ -0,0 +1,172 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgES384 int64 = -35
+	AlgES512 int64 = -36
+	AlgPS256 int64 = -37
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms lists the algorithms offered at registration, in order of preference.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256, AlgPS256, AlgES384, AlgES512}
+
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1 // also RSA modulus n
+	coseX         = -2 // also RSA exponent e
+	coseY         = -3
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+)
+
+// PublicKey is a decoded COSE_Key.
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as stored with a credential.
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	key, rest, err := parseCOSEKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("cose: trailing data after key")
+	}
+	return key, nil
+}
+
+// parseCOSEKey decodes a COSE_Key at the start of data and returns the bytes after it.
+func parseCOSEKey(data []byte) (*PublicKey, []byte, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, errors.New("cose: key is not a map")
+	}
+
+	kty, _ := intLabel(m, coseKeyType)
+	alg, ok := intLabel(m, coseAlgorithm)
+	if !ok {
+		return nil, nil, errors.New("cose: key has no algorithm")
+	}
+
+	switch kty {
+	case coseKtyOKP:
+		crv, _ := intLabel(m, coseCurve)
+		x, _ := bytesLabel(m, coseX)
+		if alg != AlgEdDSA || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("cose: unsupported OKP key")
+		}
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, rest, nil
+
+	case coseKtyEC2:
+		crv, _ := intLabel(m, coseCurve)
+		x, _ := bytesLabel(m, coseX)
+		y, _ := bytesLabel(m, coseY)
+		var curve elliptic.Curve
+		switch {
+		case crv == 1 && alg == AlgES256:
+			curve = elliptic.P256()
+		case crv == 2 && alg == AlgES384:
+			curve = elliptic.P384()
+		case crv == 3 && alg == AlgES512:
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("cose: unsupported EC2 curve %d with algorithm %d", crv, alg)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, nil, errors.New("cose: bad EC2 coordinates")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, nil, errors.New("cose: EC2 point is not on the curve")
+		}
+		return &PublicKey{Algorithm: alg, Key: pub}, rest, nil
+
+	case coseKtyRSA:
+		n, _ := bytesLabel(m, coseCurve)
+		e, _ := bytesLabel(m, coseX)
+		if (alg != AlgRS256 && alg != AlgPS256) || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, nil, errors.New("cose: unsupported RSA key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &PublicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, rest, nil
+	}
+	return nil, nil, fmt.Errorf("cose: unsupported key type %d", kty)
+}
+
+// Verify checks a WebAuthn signature over data.
+func (k *PublicKey) Verify(data, signature []byte) error {
+	switch k.Algorithm {
+	case AlgEdDSA:
+		if !ed25519.Verify(k.Key.(ed25519.PublicKey), data, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgES256, AlgES384, AlgES512:
+		var digest []byte
+		switch k.Algorithm {
+		case AlgES256:
+			sum := sha256.Sum256(data)
+			digest = sum[:]
+		case AlgES384:
+			sum := sha512.Sum384(data)
+			digest = sum[:]
+		default:
+			sum := sha512.Sum512(data)
+			digest = sum[:]
+		}
+		if !ecdsa.VerifyASN1(k.Key.(*ecdsa.PublicKey), digest, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.Key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature)
+	case AlgPS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPSS(k.Key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature, nil)
+	}
+	return fmt.Errorf("unsupported algorithm %d", k.Algorithm)
+}
+
+func intLabel(m map[interface{}]interface{}, label int64) (int64, bool) {
+	v, ok := cborLookup(m, label)
+	if !ok {
+		return 0, false
+	}
+	return cborInt(v)
+}
+
+func bytesLabel(m map[interface{}]interface{}, label int64) ([]byte, bool) {
+	v, ok := cborLookup(m, label)
+	if !ok {
+		return nil, false
+	}
+	b, ok := v.([]byte)
+	return b, ok
+}
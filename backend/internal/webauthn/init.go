@@ -0,0 +1,41 @@
+package webauthn
+
+import (
+	"admin-dashboard/internal/config"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default is the relying party configuration used by the passkey endpoints.
+var Default Config
+
+// Init sets up Default from config. The RP ID and allowed origins default to
+// the frontend URL, which is where the browser runs the ceremonies.
+func Init() error {
+	frontend, err := url.Parse(config.AppConfig.FrontendURL)
+	if err != nil {
+		return fmt.Errorf("invalid FRONTEND_URL: %w", err)
+	}
+
+	rpID := config.AppConfig.WebAuthnRPID
+	if rpID == "" {
+		rpID = frontend.Hostname()
+	}
+
+	origins := strings.FieldsFunc(config.AppConfig.WebAuthnOrigins, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(origins) == 0 {
+		origins = []string{frontend.Scheme + "://" + frontend.Host}
+	}
+
+	Default = Config{
+		RPID:    rpID,
+		RPName:  config.AppConfig.WebAuthnRPName,
+		Origins: origins,
+		Timeout: 5 * time.Minute,
+	}
+	log.Printf("Passkeys enabled for %s (origins %s)", rpID, strings.Join(origins, ", "))
+	return nil
+}
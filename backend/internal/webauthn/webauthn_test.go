@@ -0,0 +1,342 @@
+package webauthn_test
+
+import (
+	"admin-dashboard/internal/webauthn"
+	"admin-dashboard/internal/webauthn/webauthntest"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	rpID   = "dashboard.example.com"
+	origin = "https://dashboard.example.com"
+)
+
+var rp = webauthn.Config{RPID: rpID, RPName: "Dashboard", Origins: []string{origin}, Timeout: time.Minute}
+
+func authenticators() map[string]func() *webauthntest.Authenticator {
+	return map[string]func() *webauthntest.Authenticator{
+		"ES256":   func() *webauthntest.Authenticator { return webauthntest.NewES256(rpID, origin) },
+		"Ed25519": func() *webauthntest.Authenticator { return webauthntest.NewEd25519(rpID, origin) },
+	}
+}
+
+func challenge(t *testing.T) []byte {
+	t.Helper()
+	c, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// register runs a registration ceremony that is expected to succeed.
+func register(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+	c := challenge(t)
+	credential, err := rp.VerifyRegistration(c, authenticator.Register(c))
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return credential
+}
+
+func TestCeremonies(t *testing.T) {
+	for name, newAuthenticator := range authenticators() {
+		t.Run(name, func(t *testing.T) {
+			authenticator := newAuthenticator()
+
+			credential := register(t, authenticator)
+			if !bytes.Equal(credential.ID, authenticator.CredentialID) {
+				t.Errorf("credential ID = %x, want %x", credential.ID, authenticator.CredentialID)
+			}
+			if !bytes.Equal(credential.PublicKey, authenticator.PublicKey()) {
+				t.Error("stored public key differs from the authenticator's")
+			}
+			if !credential.UserVerified || credential.SignCount != 0 {
+				t.Errorf("credential = %+v, want user verified with counter 0", credential)
+			}
+
+			signCount := credential.SignCount
+			for i := 0; i < 2; i++ {
+				c := challenge(t)
+				result, err := rp.VerifyAssertion(c, authenticator.Login(c), credential.PublicKey, signCount)
+				if err != nil {
+					t.Fatalf("login %d: %v", i+1, err)
+				}
+				if result.SignCount != signCount+1 || !result.UserVerified {
+					t.Errorf("login %d: result = %+v", i+1, result)
+				}
+				signCount = result.SignCount
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse
+		want   string
+	}{
+		{
+			name: "wrong origin",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				a.Origin = "https://dashboard.example.com.evil.test"
+				return a.Register(c)
+			},
+			want: "origin",
+		},
+		{
+			name: "wrong RP ID hash",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				a.RPID = "evil.test"
+				return a.Register(c)
+			},
+			want: "relying party ID mismatch",
+		},
+		{
+			name: "other challenge",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				return a.Register([]byte("some other challenge"))
+			},
+			want: "challenge mismatch",
+		},
+		{
+			name: "login client data",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				resp := a.Register(c)
+				resp.Response.ClientDataJSON = webauthntest.ClientDataJSON("webauthn.get", c, origin)
+				return resp
+			},
+			want: "client data type",
+		},
+		{
+			name: "user not present",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				a.Flags = 0
+				return a.Register(c)
+			},
+			want: "not present",
+		},
+		{
+			name: "credential ID mismatch",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				resp := a.Register(c)
+				resp.RawID = []byte("another credential")
+				return resp
+			},
+			want: "credential ID mismatch",
+		},
+		{
+			name: "truncated authData",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				resp := a.Register(c)
+				authData := a.AuthenticatorData(true)
+				resp.Response.AttestationObject = webauthntest.AttestationObject(authData[:len(authData)-1])
+				return resp
+			},
+			want: "unexpected end of data",
+		},
+		{
+			name: "trailing authData",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				resp := a.Register(c)
+				resp.Response.AttestationObject = webauthntest.AttestationObject(append(a.AuthenticatorData(true), 0x00))
+				return resp
+			},
+			want: "trailing bytes",
+		},
+		{
+			name: "no attested credential",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.RegistrationResponse {
+				resp := a.Register(c)
+				resp.Response.AttestationObject = webauthntest.AttestationObject(a.AuthenticatorData(false))
+				return resp
+			},
+			want: "no attested credential data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := challenge(t)
+			_, err := rp.VerifyRegistration(c, tt.tamper(webauthntest.NewES256(rpID, origin), c))
+			if err == nil {
+				t.Fatal("registration accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse
+		want   string
+	}{
+		{
+			name: "wrong origin",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				a.Origin = "http://dashboard.example.com"
+				return a.Login(c)
+			},
+			want: "origin",
+		},
+		{
+			name: "wrong RP ID hash",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				a.RPID = "example.com"
+				return a.Login(c)
+			},
+			want: "relying party ID mismatch",
+		},
+		{
+			name: "other challenge",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				return a.Login(append([]byte(nil), c[1:]...))
+			},
+			want: "challenge mismatch",
+		},
+		{
+			name: "registration client data",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				resp := a.Login(c)
+				resp.Response.ClientDataJSON = webauthntest.ClientDataJSON("webauthn.create", c, origin)
+				resp.Response.Signature = a.Sign(resp.Response.AuthenticatorData, resp.Response.ClientDataJSON)
+				return resp
+			},
+			want: "client data type",
+		},
+		{
+			name: "bad signature",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				resp := a.Login(c)
+				// Signed over different client data than was sent
+				resp.Response.Signature = a.Sign(resp.Response.AuthenticatorData, webauthntest.ClientDataJSON("webauthn.get", c, "https://evil.test"))
+				return resp
+			},
+			want: "signature",
+		},
+		{
+			name: "signed by another key",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				resp := a.Login(c)
+				other := webauthntest.NewES256(rpID, origin)
+				resp.Response.Signature = other.Sign(resp.Response.AuthenticatorData, resp.Response.ClientDataJSON)
+				return resp
+			},
+			want: "signature",
+		},
+		{
+			name: "truncated authData",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				resp := a.Login(c)
+				resp.Response.AuthenticatorData = resp.Response.AuthenticatorData[:36]
+				resp.Response.Signature = a.Sign(resp.Response.AuthenticatorData, resp.Response.ClientDataJSON)
+				return resp
+			},
+			want: "too short",
+		},
+		{
+			name: "trailing authData",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				resp := a.Login(c)
+				resp.Response.AuthenticatorData = append(resp.Response.AuthenticatorData, 0xa0)
+				resp.Response.Signature = a.Sign(resp.Response.AuthenticatorData, resp.Response.ClientDataJSON)
+				return resp
+			},
+			want: "trailing bytes",
+		},
+		{
+			name: "user not present",
+			tamper: func(a *webauthntest.Authenticator, c []byte) *webauthn.AssertionResponse {
+				a.Flags = webauthn.FlagUserVerified
+				return a.Login(c)
+			},
+			want: "not present",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := webauthntest.NewES256(rpID, origin)
+			credential := register(t, authenticator)
+
+			c := challenge(t)
+			_, err := rp.VerifyAssertion(c, tt.tamper(authenticator, c), credential.PublicKey, credential.SignCount)
+			if err == nil {
+				t.Fatal("assertion accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionSignCount(t *testing.T) {
+	tests := []struct {
+		name          string
+		stored, sent  uint32
+		wantRegressed bool
+	}{
+		{"increased", 5, 6, false},
+		{"repeated", 5, 5, true},
+		{"went backwards", 5, 2, true},
+		{"reset to zero", 5, 0, true},
+		{"no counter", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := webauthntest.NewEd25519(rpID, origin)
+			credential := register(t, authenticator)
+
+			// Login bumps the counter before signing
+			authenticator.SignCount = tt.sent - 1
+			c := challenge(t)
+			_, err := rp.VerifyAssertion(c, authenticator.Login(c), credential.PublicKey, tt.stored)
+			if regressed := errors.Is(err, webauthn.ErrSignCountRegressed); regressed != tt.wantRegressed {
+				t.Errorf("error = %v, want regressed %v", err, tt.wantRegressed)
+			}
+			if !tt.wantRegressed && err != nil {
+				t.Errorf("VerifyAssertion: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	authenticator := webauthntest.NewES256(rpID, origin)
+	authenticator.SignCount = 7
+	attested := authenticator.AuthenticatorData(true)
+
+	authData, err := webauthn.ParseAuthenticatorData(attested)
+	if err != nil {
+		t.Fatalf("ParseAuthenticatorData: %v", err)
+	}
+	if authData.SignCount != 7 || !bytes.Equal(authData.CredentialID, authenticator.CredentialID) || !bytes.Equal(authData.PublicKey, authenticator.PublicKey()) {
+		t.Errorf("unexpected authenticator data: %+v", authData)
+	}
+
+	// Every truncation of attested data has to fail rather than read past the end
+	for n := 0; n < len(attested); n++ {
+		if _, err := webauthn.ParseAuthenticatorData(attested[:n]); err == nil {
+			t.Errorf("authenticator data truncated to %d of %d bytes was accepted", n, len(attested))
+		}
+	}
+
+	// A credential ID length pointing past the end
+	bad := append([]byte(nil), attested...)
+	bad[37+16], bad[37+17] = 0xff, 0xff
+	if _, err := webauthn.ParseAuthenticatorData(bad); err == nil {
+		t.Error("oversized credential ID length was accepted")
+	}
+}
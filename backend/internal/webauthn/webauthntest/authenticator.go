@@ -0,0 +1,244 @@
+// Package webauthntest provides a software authenticator that answers
+// registration and login ceremonies like a browser would, for testing the
+// relying party without one.
+package webauthntest
+
+import (
+	"admin-dashboard/internal/webauthn"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+)
+
+// Authenticator holds one credential. Its fields can be changed between
+// ceremonies to make it misbehave.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	CredentialID []byte
+	// UserHandle is sent with assertions, as discoverable credentials do
+	UserHandle []byte
+	// SignCount is incremented before every assertion
+	SignCount uint32
+	Flags     byte
+
+	algorithm int64
+	signer    crypto.Signer
+}
+
+// NewES256 returns an authenticator with a P-256 key.
+func NewES256(rpID, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return newAuthenticator(rpID, origin, webauthn.AlgES256, key)
+}
+
+// NewEd25519 returns an authenticator with an Ed25519 key.
+func NewEd25519(rpID, origin string) *Authenticator {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return newAuthenticator(rpID, origin, webauthn.AlgEdDSA, key)
+}
+
+func newAuthenticator(rpID, origin string, algorithm int64, signer crypto.Signer) *Authenticator {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		CredentialID: id,
+		Flags:        webauthn.FlagUserPresent | webauthn.FlagUserVerified,
+		algorithm:    algorithm,
+		signer:       signer,
+	}
+}
+
+// PublicKey returns the credential's public key as a COSE_Key.
+func (a *Authenticator) PublicKey() []byte {
+	switch key := a.signer.Public().(type) {
+	case ed25519.PublicKey:
+		return encodeMap(map[int64]interface{}{1: int64(1), 3: a.algorithm, -1: int64(6), -2: []byte(key)})
+	case *ecdsa.PublicKey:
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			panic(err)
+		}
+		// Uncompressed point: 0x04 || x || y
+		point := ecdhKey.Bytes()[1:]
+		size := len(point) / 2
+		return encodeMap(map[int64]interface{}{1: int64(2), 3: a.algorithm, -1: int64(1), -2: point[:size], -3: point[size:]})
+	}
+	panic("webauthntest: unsupported key")
+}
+
+// AuthenticatorData builds authenticator data for the current state, with
+// the attested credential data when attested is set.
+func (a *Authenticator) AuthenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append([]byte(nil), rpIDHash[:]...)
+
+	flags := a.Flags
+	if attested {
+		flags |= webauthn.FlagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.CredentialID)))
+		data = append(data, a.CredentialID...)
+		data = append(data, a.PublicKey()...)
+	}
+	return data
+}
+
+// Register answers a registration ceremony for challenge.
+func (a *Authenticator) Register(challenge []byte) *webauthn.RegistrationResponse {
+	resp := &webauthn.RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = ClientDataJSON("webauthn.create", challenge, a.Origin)
+	resp.Response.AttestationObject = AttestationObject(a.AuthenticatorData(true))
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+// Login answers a login ceremony for challenge, after bumping the counter.
+func (a *Authenticator) Login(challenge []byte) *webauthn.AssertionResponse {
+	a.SignCount++
+
+	resp := &webauthn.AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = ClientDataJSON("webauthn.get", challenge, a.Origin)
+	resp.Response.AuthenticatorData = a.AuthenticatorData(false)
+	resp.Response.Signature = a.Sign(resp.Response.AuthenticatorData, resp.Response.ClientDataJSON)
+	resp.Response.UserHandle = a.UserHandle
+	return resp
+}
+
+// Sign signs authenticator data and client data the way assertions are
+// signed, so tests can re-sign responses they tampered with.
+func (a *Authenticator) Sign(authenticatorData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+
+	switch key := a.signer.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, signed)
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(signed)
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			panic(err)
+		}
+		return signature
+	}
+	panic("webauthntest: unsupported key")
+}
+
+// ClientDataJSON builds the client data a browser collects for a ceremony.
+func ClientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	data, err := json.Marshal(webauthn.ClientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    origin,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// AttestationObject wraps authenticator data in a "none" attestation.
+func AttestationObject(authenticatorData []byte) []byte {
+	// Keys in CTAP2 canonical order: shorter first
+	object := cborHead(5, 3)
+	object = append(object, cborText("fmt")...)
+	object = append(object, cborText("none")...)
+	object = append(object, cborText("attStmt")...)
+	object = append(object, cborHead(5, 0)...)
+	object = append(object, cborText("authData")...)
+	object = append(object, cborBytes(authenticatorData)...)
+	return object
+}
+
+// encodeMap encodes a map with integer labels, as COSE keys use, in
+// canonical order.
+func encodeMap(m map[int64]interface{}) []byte {
+	labels := make([]int64, 0, len(m))
+	for label := range m {
+		labels = append(labels, label)
+	}
+	// Canonical CBOR puts positive integers before negative ones, each by size
+	sort.Slice(labels, func(i, j int) bool {
+		if (labels[i] < 0) != (labels[j] < 0) {
+			return labels[i] >= 0
+		}
+		if labels[i] < 0 {
+			return labels[i] > labels[j]
+		}
+		return labels[i] < labels[j]
+	})
+
+	out := cborHead(5, uint64(len(m)))
+	for _, label := range labels {
+		out = append(out, cborInt(label)...)
+		switch v := m[label].(type) {
+		case int64:
+			out = append(out, cborInt(v)...)
+		case []byte:
+			out = append(out, cborBytes(v)...)
+		default:
+			panic("webauthntest: unsupported COSE value")
+		}
+	}
+	return out
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
@@ -17,6 +17,29 @@ const (
 	maxMessageSize = 512 * 1024
 )
 
+// close tells the client its session ended and drops the connection.
+// WriteControl and Close are safe to call alongside WritePump.
+func (c *Client) close() {
+	c.Conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"),
+		time.Now().Add(writeWait))
+	c.Conn.Close()
+}
+
+// sessionActive re-checks the session the connection was opened with, so
+// connections also end when a session is revoked outside a handler (e.g. refresh token reuse).
+func (c *Client) sessionActive() bool {
+	var count int64
+	if err := database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", c.SessionID, time.Now()).
+		Count(&count).Error; err != nil {
+		// Don't drop connections over a database hiccup
+		log.Printf("Error checking session %s: %v", c.SessionID, err)
+		return true
+	}
+	return count > 0
+}
+
 func (c *Client) ReadPump() {
 	defer func() {
 		c.Hub.Unregister(c)
@@ -135,6 +158,10 @@ func (c *Client) WritePump() {
 			}
 
 		case <-ticker.C:
+			if !c.sessionActive() {
+				c.close()
+				return
+			}
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
@@ -10,11 +10,12 @@ import (
 )
 
 type Client struct {
-	Hub      *Hub
-	Conn     *websocket.Conn
-	UserID   uuid.UUID
-	Username string
-	Send     chan []byte
+	Hub       *Hub
+	Conn      *websocket.Conn
+	UserID    uuid.UUID
+	Username  string
+	SessionID uuid.UUID
+	Send      chan []byte
 }
 
 type Hub struct {
@@ -26,12 +27,12 @@ type Hub struct {
 }
 
 type Message struct {
-	Type      string          `json:"type"`
-	SenderID  uuid.UUID       `json:"sender_id"`
-	ReceiverID *uuid.UUID     `json:"receiver_id,omitempty"`
-	Content   string          `json:"content"`
-	Timestamp string          `json:"timestamp"`
-	Raw       json.RawMessage `json:"-"`
+	Type       string          `json:"type"`
+	SenderID   uuid.UUID       `json:"sender_id"`
+	ReceiverID *uuid.UUID      `json:"receiver_id,omitempty"`
+	Content    string          `json:"content"`
+	Timestamp  string          `json:"timestamp"`
+	Raw        json.RawMessage `json:"-"`
 }
 
 func NewHub() *Hub {
@@ -109,6 +110,34 @@ func (h *Hub) Run() {
 	}
 }
 
+// CloseSession disconnects every connection opened with the given session.
+func (h *Hub) CloseSession(sessionID uuid.UUID) {
+	h.closeClients(func(client *Client) bool {
+		return client.SessionID == sessionID
+	})
+}
+
+// CloseUserSessions disconnects all of a user's connections except those of
+// the session to keep (uuid.Nil keeps none).
+func (h *Hub) CloseUserSessions(userID, keepSessionID uuid.UUID) {
+	h.closeClients(func(client *Client) bool {
+		return client.UserID == userID && (keepSessionID == uuid.Nil || client.SessionID != keepSessionID)
+	})
+}
+
+// closeClients sends a close frame to matching clients and drops their
+// connection. ReadPump then fails and unregisters them as usual.
+func (h *Hub) closeClients(match func(*Client) bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if match(client) {
+			client.close()
+		}
+	}
+}
+
 func (h *Hub) GetOnlineUsers() []OnlineUser {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -3,6 +3,7 @@ package main
 import (
 	"admin-dashboard/internal/config"
 	"admin-dashboard/internal/database"
+	_ "admin-dashboard/internal/handlers" // registers the permissions that Seed reconciles
 	"admin-dashboard/internal/models"
 	"admin-dashboard/internal/utils"
 	"fmt"
@@ -28,6 +29,10 @@ func main() {
 		log.Fatal("Failed to load config:", err)
 	}
 
+	if err := utils.InitPasswordHasher(); err != nil {
+		log.Fatal("Failed to initialize password hashing:", err)
+	}
+
 	// Connect to database
 	if err := database.Connect(); err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -1,13 +1,15 @@
 'use client'
 
-import { useState } from 'react'
+import { useEffect, useRef, useState } from 'react'
 import Link from 'next/link'
-import { useRouter, useParams, usePathname } from 'next/navigation'
+import { useRouter, useParams, usePathname, useSearchParams } from 'next/navigation'
 import { PasswordInput } from '@/components/ui/PasswordInput'
 import { useMutation } from '@tanstack/react-query'
 import { toast } from 'sonner'
 import { useDictionary } from '@/contexts/DictionaryContext'
-import { authService } from '@/services/auth'
+import { API_URL } from '@/services/api'
+import { authService, LoginResponse, VerifyCaptchaResponse } from '@/services/auth'
+import { passkeyService, passkeysSupported } from '@/services/passkeys'
 import { useAuthStore } from '@/stores/authStore'
 
 type LoginStep = 'credentials' | 'captcha'
@@ -21,26 +23,54 @@ export default function LoginPage() {
   const router = useRouter()
   const params = useParams()
   const pathname = usePathname()
+  const searchParams = useSearchParams()
+  const magicToken = searchParams?.get('magic_token')
   const lang = params?.lang as string
   const [step, setStep] = useState<LoginStep>('credentials')
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
   const [captchaId, setCaptchaId] = useState('')
   const [captchaQuestion, setCaptchaQuestion] = useState('')
+  const [captchaType, setCaptchaType] = useState('')
   const [captchaAnswer, setCaptchaAnswer] = useState('')
   const { setAuth } = useAuthStore()
+  // Magic-link tokens are single-use, so make sure one is only sent once
+  const magicTokenSent = useRef(false)
+
+  const showCaptcha = (data: LoginResponse) => {
+    if (data.requires_captcha && data.captcha_id) {
+      setCaptchaId(data.captcha_id)
+      setCaptchaQuestion(data.captcha_question || '')
+      setCaptchaType(data.captcha_type || '')
+      setStep('captcha')
+    }
+  }
 
   const loginMutation = useMutation({
     mutationFn: authService.login,
-    onSuccess: (data) => {
-      if (data.requires_captcha && data.captcha_id) {
-        setCaptchaId(data.captcha_id)
-        setCaptchaQuestion(data.captcha_question || '')
-        setStep('captcha')
-      }
-    },
+    onSuccess: showCaptcha,
   })
 
+  const magicLinkMutation = useMutation({
+    mutationFn: authService.requestMagicLink,
+    onSuccess: () => toast.success(t('auth.magicLinkSent')),
+    onError: () => toast.error(t('auth.magicLinkSendFailed')),
+  })
+
+  useEffect(() => {
+    if (!magicToken || magicTokenSent.current) return
+    magicTokenSent.current = true
+    router.replace(`/${lang}/auth/login`)
+
+    authService
+      .verifyMagicLink(magicToken)
+      .then((data) => {
+        if (data.username) setUsername(data.username)
+        showCaptcha(data)
+      })
+      .catch(() => toast.error(t('auth.magicLinkInvalid')))
+  }, [lang, magicToken, router, t])
+
   const getDefaultPermissions = (roleName?: string) => {
     if (roleName === 'admin')
       return ['USER_CREATE', 'USER_READ', 'USER_UPDATE', 'USER_DELETE', 'ROLE_MANAGE', 'ANALYTICS_VIEW', 'CHAT_SEND']
@@ -48,33 +78,55 @@ export default function LoginPage() {
     return ['USER_READ', 'ANALYTICS_VIEW', 'CHAT_SEND']
   }
 
+  const finishLogin = async (data: VerifyCaptchaResponse) => {
+    let permissions: string[] = []
+    try {
+      const token = data.token
+      if (typeof window !== 'undefined') {
+        localStorage.setItem('token', token)
+        localStorage.setItem('refresh_token', data.refresh_token)
+      }
+
+      const response = await fetch(
+        `${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'}/me`,
+        { headers: { Authorization: `Bearer ${token}` } }
+      )
+
+      permissions = response.ok
+        ? (await response.json()).permissions || []
+        : getDefaultPermissions(data.user.role?.name)
+    } catch (error) {
+      console.error('Error fetching user permissions:', error)
+      permissions = getDefaultPermissions(data.user.role?.name)
+    }
+
+    setAuth(data.user, data.token, permissions)
+    toast.success(t('auth.loginSuccess'))
+    router.push(`/${lang}/dashboard`)
+  }
+
   const verifyCaptchaMutation = useMutation({
     mutationFn: authService.verifyCaptcha,
-    onSuccess: async (data) => {
-      let permissions: string[] = []
-      try {
-        const token = data.token
-        if (typeof window !== 'undefined') {
-          localStorage.setItem('token', token)
-        }
-
-        const response = await fetch(
-          `${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'}/me`,
-          { headers: { Authorization: `Bearer ${token}` } }
-        )
-
-        permissions = response.ok
-          ? (await response.json()).permissions || []
-          : getDefaultPermissions(data.user.role?.name)
-      } catch (error) {
-        console.error('Error fetching user permissions:', error)
-        permissions = getDefaultPermissions(data.user.role?.name)
+    onSuccess: finishLogin,
+    onError: (error) => {
+      // Expired or used-up captchas can't be retried; start over from the password step
+      const err = error as { response?: { data?: { error_code?: string } } }
+      if (err.response?.data?.error_code === 'CAPTCHA_EXPIRED') {
+        toast.error(t('auth.captchaExpired'))
+        setCaptchaAnswer('')
+        setStep('credentials')
       }
+    },
+  })
 
-      setAuth(data.user, data.token, permissions)
-      toast.success(t('auth.loginSuccess'))
-      router.push(`/${lang}/dashboard`)
+  const passkeyMutation = useMutation({
+    mutationFn: () => passkeyService.login(username || undefined),
+    onSuccess: (data) => {
+      // Without user verification the server asks for the 2FA code instead
+      if (data.token) return finishLogin(data)
+      toast.error(t('auth.passkeyFailed'))
     },
+    onError: () => toast.error(t('auth.passkeyFailed')),
   })
 
   const handleCredentialsSubmit = (e: React.FormEvent) => {
@@ -82,6 +134,14 @@ export default function LoginPage() {
     loginMutation.mutate({ username, password })
   }
 
+  const handleMagicLink = () => {
+    if (!username.includes('@')) {
+      toast.error(t('auth.magicLinkNeedsEmail'))
+      return
+    }
+    magicLinkMutation.mutate(username)
+  }
+
   const handleCaptchaSubmit = (e: React.FormEvent) => {
     e.preventDefault()
     verifyCaptchaMutation.mutate({ username, captcha_id: captchaId, answer: captchaAnswer })
@@ -128,7 +188,7 @@ export default function LoginPage() {
           <form className="mt-8 space-y-6" onSubmit={handleCredentialsSubmit}>
             <div>
               <label htmlFor="username" className="block text-sm font-medium text-gray-700 dark:text-gray-300">
-                {t('auth.username')}
+                {t('auth.usernameOrEmail')}
               </label>
               <input
                 id="username"
@@ -170,6 +230,35 @@ export default function LoginPage() {
               {loginMutation.isPending ? t('auth.loggingIn') : t('auth.login')}
             </button>
 
+            {passkeysSupported() && (
+              <button
+                type="button"
+                onClick={() => passkeyMutation.mutate()}
+                disabled={passkeyMutation.isPending}
+                className="flex w-full justify-center rounded-md border border-gray-300 bg-white px-4 py-2 text-sm font-medium text-gray-700 shadow-sm hover:bg-gray-50 disabled:opacity-50 dark:border-gray-600 dark:bg-gray-700 dark:text-gray-200 dark:hover:bg-gray-600"
+              >
+                {t('auth.passkeyLogin')}
+              </button>
+            )}
+
+            <button
+              type="button"
+              onClick={handleMagicLink}
+              disabled={magicLinkMutation.isPending}
+              className="flex w-full justify-center rounded-md border border-gray-300 bg-white px-4 py-2 text-sm font-medium text-gray-700 shadow-sm hover:bg-gray-50 disabled:opacity-50 dark:border-gray-600 dark:bg-gray-700 dark:text-gray-200 dark:hover:bg-gray-600"
+            >
+              {t('auth.magicLinkLogin')}
+            </button>
+
+            {process.env.NEXT_PUBLIC_SSO_ENABLED === 'true' && (
+              <a
+                href={`${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'}/auth/oidc/login`}
+                className="flex w-full justify-center rounded-md border border-gray-300 bg-white px-4 py-2 text-sm font-medium text-gray-700 shadow-sm hover:bg-gray-50 dark:border-gray-600 dark:bg-gray-700 dark:text-gray-200 dark:hover:bg-gray-600"
+              >
+                {t('auth.ssoLogin')}
+              </a>
+            )}
+
             <p className="text-center text-sm text-gray-600 dark:text-gray-400">
               {t('auth.dontHaveAccount')}{' '}
               <Link
@@ -188,6 +277,16 @@ export default function LoginPage() {
               <label className="mb-2 block text-sm font-medium text-gray-700 dark:text-gray-300">
                 {captchaQuestion}
               </label>
+              {captchaType === 'image' && (
+                // eslint-disable-next-line @next/next/no-img-element
+                <img
+                  src={`${API_URL}/auth/captcha/${encodeURIComponent(captchaId)}/image`}
+                  alt={captchaQuestion}
+                  width={200}
+                  height={70}
+                  className="mb-2 rounded-md border border-gray-300 dark:border-gray-600"
+                />
+              )}
               <input
                 type="text"
                 required
@@ -2,9 +2,10 @@
 
 import { useState } from 'react'
 import Link from 'next/link'
-import { useRouter, useParams, usePathname } from 'next/navigation'
+import { useRouter, useParams, usePathname, useSearchParams } from 'next/navigation'
 import { PasswordInput } from '@/components/ui/PasswordInput'
-import { useMutation } from '@tanstack/react-query'
+import { useMutation, useQuery } from '@tanstack/react-query'
+import { toast } from 'sonner'
 import { useDictionary } from '@/contexts/DictionaryContext'
 import { authService } from '@/services/auth'
 import { useAuthStore } from '@/stores/authStore'
@@ -28,13 +29,28 @@ export default function RegisterPage() {
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
   const [fullName, setFullName] = useState('')
+  // Invitation links look like /auth/register?code=inv_...
+  const searchParams = useSearchParams()
+  const [invitationCode, setInvitationCode] = useState(searchParams?.get('code') || '')
+  const { data: registrationMode } = useQuery({
+    queryKey: ['registration-mode'],
+    queryFn: authService.getRegistrationMode,
+  })
   const { setAuth } = useAuthStore()
 
   const registerMutation = useMutation({
     mutationFn: authService.register,
     onSuccess: async (data) => {
+      // Roles that require 2FA get no session until it is set up at login
+      if (data.requires_totp_setup) {
+        toast.success(t('auth.registeredSetUp2FA'))
+        router.push(`/${lang}/auth/login`)
+        return
+      }
+
       if (typeof window !== 'undefined') {
         localStorage.setItem('token', data.token)
+        localStorage.setItem('refresh_token', data.refresh_token)
       }
 
       try {
@@ -62,6 +78,7 @@ export default function RegisterPage() {
       username,
       password,
       ...(fullName.trim() && { full_name: fullName.trim() }),
+      ...(invitationCode.trim() && { invitation_code: invitationCode.trim() }),
     })
   }
 
@@ -171,6 +188,29 @@ export default function RegisterPage() {
             />
           </div>
 
+          {registrationMode !== 'disabled' && (
+            <div>
+              <label htmlFor="invitationCode" className="block text-sm font-medium text-gray-700 dark:text-gray-300">
+                {t('auth.invitationCode')} {registrationMode === 'invite' ? '*' : `(${t('auth.optional')})`}
+              </label>
+              <input
+                id="invitationCode"
+                name="invitationCode"
+                type="text"
+                required={registrationMode === 'invite'}
+                value={invitationCode}
+                onChange={(e) => setInvitationCode(e.target.value)}
+                className="mt-1 block w-full rounded-md border border-gray-300 px-3 py-2 shadow-sm focus:border-primary-500 focus:outline-none focus:ring-primary-500 dark:border-gray-600 dark:bg-gray-700 dark:text-gray-100"
+              />
+            </div>
+          )}
+
+          {registrationMode === 'disabled' && (
+            <div className="rounded-md bg-yellow-50 px-3 py-2 text-sm text-yellow-800 dark:bg-yellow-900/20 dark:text-yellow-400">
+              {t('auth.errors.REGISTRATION_DISABLED')}
+            </div>
+          )}
+
           {errorMessage && (
             <div className="rounded-md bg-red-50 px-3 py-2 text-sm text-red-700 dark:bg-red-900/20 dark:text-red-400">
               {errorMessage}
@@ -0,0 +1,59 @@
+'use client'
+
+import { useEffect } from 'react'
+import { useRouter, useParams } from 'next/navigation'
+import { toast } from 'sonner'
+import { useDictionary } from '@/contexts/DictionaryContext'
+import { authService } from '@/services/auth'
+import { useAuthStore } from '@/stores/authStore'
+import { PageSpinner } from '@/components/ui/PageSpinner'
+
+export default function SSOCallbackPage() {
+  const { t } = useDictionary()
+  const router = useRouter()
+  const params = useParams()
+  const lang = params?.lang as string
+  const { setAuth } = useAuthStore()
+
+  useEffect(() => {
+    // The backend puts a single-use code in the fragment so it never reaches a server log
+    const fragment = new URLSearchParams(window.location.hash.slice(1))
+    const code = fragment.get('code')
+    window.history.replaceState(null, '', window.location.pathname)
+
+    if (!code) {
+      toast.error(t('auth.ssoFailed'))
+      router.replace(`/${lang}/auth/login`)
+      return
+    }
+
+    authService
+      .completeSSOLogin(code)
+      .then(async (login) => {
+        if (!login.token || !login.refresh_token) {
+          // The account has or needs 2FA, which this page can't ask for
+          toast.error(t('auth.ssoTwoFactorRequired'))
+          router.replace(`/${lang}/auth/login`)
+          return
+        }
+        const token = login.token
+        localStorage.setItem('token', token)
+        localStorage.setItem('refresh_token', login.refresh_token)
+
+        const response = await fetch(`${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'}/me`, {
+          headers: { Authorization: `Bearer ${token}` },
+        })
+        if (!response.ok) throw new Error('Failed to load user')
+        const data = await response.json()
+        setAuth(data.user, token, data.permissions || [])
+        toast.success(t('auth.loginSuccess'))
+        router.replace(`/${lang}/dashboard`)
+      })
+      .catch(() => {
+        toast.error(t('auth.ssoFailed'))
+        router.replace(`/${lang}/auth/login`)
+      })
+  }, [lang, router, setAuth, t])
+
+  return <PageSpinner message={t('auth.ssoSigningIn')} />
+}
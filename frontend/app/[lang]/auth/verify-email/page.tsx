@@ -0,0 +1,33 @@
+'use client'
+
+import { useEffect } from 'react'
+import { useRouter, useParams, useSearchParams } from 'next/navigation'
+import { toast } from 'sonner'
+import { useDictionary } from '@/contexts/DictionaryContext'
+import { authService } from '@/services/auth'
+import { PageSpinner } from '@/components/ui/PageSpinner'
+
+export default function VerifyEmailPage() {
+  const { t } = useDictionary()
+  const router = useRouter()
+  const params = useParams()
+  const searchParams = useSearchParams()
+  const lang = params?.lang as string
+  const token = searchParams?.get('token')
+
+  useEffect(() => {
+    if (!token) {
+      toast.error(t('auth.emailVerifyFailed'))
+      router.replace(`/${lang}/auth/login`)
+      return
+    }
+
+    authService
+      .verifyEmail(token)
+      .then(() => toast.success(t('auth.emailVerified')))
+      .catch(() => toast.error(t('auth.emailVerifyFailed')))
+      .finally(() => router.replace(`/${lang}/auth/login`))
+  }, [lang, router, t, token])
+
+  return <PageSpinner message={t('auth.emailVerifying')} />
+}
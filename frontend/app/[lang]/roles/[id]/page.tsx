@@ -51,6 +51,15 @@ export default function RoleDetailPage() {
     }
   }, [roleData?.permissions])
 
+  // Permissions the role gets from an ancestor, by permission ID
+  const inheritedFrom = useMemo(() => {
+    const sources = new Map<string, string>()
+    for (const p of roleData?.effective_permissions ?? []) {
+      if (p.inherited) sources.set(p.id, p.source_role_name)
+    }
+    return sources
+  }, [roleData?.effective_permissions])
+
   const groupedPermissions = useMemo(() => {
     if (!allPermissions) return []
     const groups: Record<string, Permission[]> = {}
@@ -113,6 +122,11 @@ export default function RoleDetailPage() {
         <p className="mb-6 text-gray-500 dark:text-gray-400">
           {roleData.role.description || t('common.na')}
         </p>
+        {roleData.role.parent && (
+          <p className="-mt-4 mb-6 text-sm text-gray-500 dark:text-gray-400">
+            {t('roles.inheritsFrom')} {roleData.role.parent.name}
+          </p>
+        )}
 
         <div className="max-w-2xl space-y-6">
           {groupedPermissions.map(({ key, permissions }) => (
@@ -133,6 +147,11 @@ export default function RoleDetailPage() {
                       className="rounded border-gray-300 text-primary-600 focus:ring-primary-500 dark:border-gray-600 dark:bg-gray-700"
                     />
                     <span className="text-sm text-gray-700 dark:text-gray-300">{perm.name}</span>
+                    {inheritedFrom.has(perm.id) && (
+                      <span className="text-xs text-gray-500 dark:text-gray-400">
+                        ({t('roles.inheritedFrom')} {inheritedFrom.get(perm.id)})
+                      </span>
+                    )}
                   </label>
                 ))}
               </div>
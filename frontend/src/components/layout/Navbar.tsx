@@ -2,6 +2,7 @@
 
 import { useState, useRef, useEffect } from 'react'
 import { useAuthStore } from '@/stores/authStore'
+import { authService } from '@/services/auth'
 import { useRouter, useParams, usePathname } from 'next/navigation'
 import { useTheme } from 'next-themes'
 import { useDictionary } from '@/contexts/DictionaryContext'
@@ -39,6 +40,8 @@ export function Navbar({ onToggleSidebar, isSidebarExpanded }: NavbarProps) {
   }, [userMenuOpen])
 
   const handleLogout = () => {
+    // Best effort: revoke the token server-side, but log out locally regardless
+    authService.logout().catch(() => {})
     clearAuth()
     router.push(`/${lang}/auth/login`)
   }
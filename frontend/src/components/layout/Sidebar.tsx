@@ -1,6 +1,7 @@
 'use client'
 
 import { useAuthStore } from '@/stores/authStore'
+import { authService } from '@/services/auth'
 import { useChatStore } from '@/stores/chatStore'
 import { usePathname, useRouter, useParams } from 'next/navigation'
 import { TransitionLocaleLink } from '@/components/TransitionLocaleLink'
@@ -38,6 +39,8 @@ export function Sidebar({ isExpanded }: SidebarProps) {
   const { t } = useDictionary()
 
   const handleLogout = () => {
+    // Best effort: revoke the token server-side, but log out locally regardless
+    authService.logout().catch(() => {})
     clearAuth()
     router.push(`/${lang}/auth/login`)
   }
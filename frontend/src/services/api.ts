@@ -1,6 +1,7 @@
 import axios from 'axios'
+import { useAuthStore } from '@/stores/authStore'
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'
+export const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:4010/api'
 
 export const api = axios.create({
   baseURL: API_URL,
@@ -25,16 +26,48 @@ api.interceptors.request.use(
   }
 )
 
-// Handle token expiration
+// Shared so that concurrent 401s trigger a single refresh (refresh tokens rotate)
+let refreshPromise: Promise<string> | null = null
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshPromise = (
+      refreshToken
+        ? axios.post(`${API_URL}/auth/refresh`, { refresh_token: refreshToken }).then((response) => {
+            localStorage.setItem('token', response.data.token)
+            localStorage.setItem('refresh_token', response.data.refresh_token)
+            useAuthStore.setState({ token: response.data.token })
+            return response.data.token as string
+          })
+        : Promise.reject(new Error('No refresh token'))
+    ).finally(() => {
+      refreshPromise = null
+    })
+  }
+  return refreshPromise
+}
+
+// Handle token expiration: try a refresh once, then fall back to the login page
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      if (typeof window !== 'undefined') {
-        localStorage.removeItem('token')
-        localStorage.removeItem('user')
-        window.location.href = '/auth/login'
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && typeof window !== 'undefined') {
+      if (original && !original._retry && !original.url?.startsWith('/auth/')) {
+        original._retry = true
+        try {
+          const token = await refreshAccessToken()
+          original.headers.Authorization = `Bearer ${token}`
+          return api(original)
+        } catch {
+          // Fall through to logout
+        }
       }
+      localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
+      localStorage.removeItem('user')
+      window.location.href = '/auth/login'
     }
     return Promise.reject(error)
   }
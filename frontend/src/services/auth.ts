@@ -9,16 +9,26 @@ export interface RegisterRequest {
   username: string
   password: string
   full_name?: string
+  invitation_code?: string
 }
 
+export type RegistrationMode = 'open' | 'invite' | 'disabled'
+
 export interface RegisterResponse {
   token: string
+  refresh_token: string
+  expires_at: string
   user: User
+  // Set instead of the tokens when the new account's role requires 2FA
+  requires_totp_setup?: boolean
+  mfa_token?: string
 }
 
+// A sample of the captcha step; it can't be answered
 export interface CaptchaResponse {
-  id: string
+  type: string
   question: string
+  image?: string
 }
 
 export interface LoginResponse {
@@ -26,6 +36,8 @@ export interface LoginResponse {
   requires_captcha: boolean
   captcha_id?: string
   captcha_question?: string
+  captcha_type?: 'math' | 'text' | 'image'
+  username?: string
 }
 
 export interface VerifyCaptchaRequest {
@@ -38,6 +50,8 @@ export interface User {
   id: string
   full_name: string
   username: string
+  email?: string
+  email_verified?: boolean
   is_active: boolean
   role_id: string
   role?: {
@@ -45,14 +59,29 @@ export interface User {
     name: string
     description: string
   }
+  roles?: {
+    id: string
+    name: string
+    description: string
+  }[]
+  effective_permissions?: string[]
   created_at: string
 }
 
 export interface VerifyCaptchaResponse {
   token: string
+  refresh_token: string
+  expires_at: string
   user: User
 }
 
+// Logins that still need a second factor answer with an MFA token instead of tokens
+export interface SSOCompleteResponse extends Partial<VerifyCaptchaResponse> {
+  requires_totp?: boolean
+  requires_totp_setup?: boolean
+  mfa_token?: string
+}
+
 export const authService = {
   login: async (data: LoginRequest): Promise<LoginResponse> => {
     const response = await api.post('/auth/login', data)
@@ -64,6 +93,11 @@ export const authService = {
     return response.data
   },
 
+  getRegistrationMode: async (): Promise<RegistrationMode> => {
+    const response = await api.get('/auth/registration')
+    return response.data.mode
+  },
+
   getCaptcha: async (): Promise<CaptchaResponse> => {
     const response = await api.get('/auth/captcha')
     return response.data
@@ -73,4 +107,38 @@ export const authService = {
     const response = await api.post('/auth/verify-captcha', data)
     return response.data
   },
+
+  // Finishes a login that answered PASSWORD_CHANGE_REQUIRED with a password_change_token
+  changeExpiredPassword: async (passwordChangeToken: string, newPassword: string): Promise<VerifyCaptchaResponse> => {
+    const response = await api.post('/auth/expired-password', {
+      password_change_token: passwordChangeToken,
+      new_password: newPassword,
+    })
+    return response.data
+  },
+
+  completeSSOLogin: async (code: string): Promise<SSOCompleteResponse> => {
+    const response = await api.post('/auth/oidc/complete', { code })
+    return response.data
+  },
+
+  verifyEmail: async (token: string): Promise<void> => {
+    await api.post('/auth/verify-email', { token })
+  },
+
+  requestMagicLink: async (email: string): Promise<void> => {
+    await api.post('/auth/magic-link', { email })
+  },
+
+  verifyMagicLink: async (token: string): Promise<LoginResponse> => {
+    const response = await api.post('/auth/magic-link/verify', { token })
+    return response.data
+  },
+
+  logout: async (): Promise<void> => {
+    // Read the token now: callers usually clear local auth right after calling this
+    const token = localStorage.getItem('token')
+    if (!token) return
+    await api.post('/auth/logout', null, { headers: { Authorization: `Bearer ${token}` } })
+  },
 }
@@ -0,0 +1,102 @@
+import api from './api'
+import { VerifyCaptchaResponse } from './auth'
+
+export interface Passkey {
+  id: string
+  name: string
+  algorithm: number
+  backup_eligible: boolean
+  last_used_at?: string
+  created_at: string
+}
+
+// WebAuthn binary fields travel as unpadded base64url in JSON
+function toBase64url(buffer: ArrayBuffer): string {
+  let binary = ''
+  new Uint8Array(buffer).forEach((b) => (binary += String.fromCharCode(b)))
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+function fromBase64url(value: string): ArrayBuffer {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const binary = atob(base64.padEnd(Math.ceil(base64.length / 4) * 4, '='))
+  return Uint8Array.from(binary, (c) => c.charCodeAt(0)).buffer
+}
+
+type Descriptor = { type: 'public-key'; id: string; transports?: AuthenticatorTransport[] }
+
+const toDescriptors = (list?: Descriptor[]): PublicKeyCredentialDescriptor[] =>
+  (list || []).map((d) => ({ ...d, id: fromBase64url(d.id) }))
+
+export const passkeysSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential
+
+export const passkeyService = {
+  list: async (): Promise<Passkey[]> => {
+    const response = await api.get('/me/passkeys')
+    return response.data
+  },
+
+  register: async (name: string): Promise<Passkey> => {
+    const { data: options } = await api.post('/me/passkeys/register/begin')
+    const credential = (await navigator.credentials.create({
+      publicKey: {
+        ...options,
+        challenge: fromBase64url(options.challenge),
+        user: { ...options.user, id: fromBase64url(options.user.id) },
+        excludeCredentials: toDescriptors(options.excludeCredentials),
+      },
+    })) as PublicKeyCredential
+    const response = credential.response as AuthenticatorAttestationResponse
+
+    const { data } = await api.post('/me/passkeys/register/finish', {
+      name,
+      credential: {
+        id: credential.id,
+        rawId: toBase64url(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64url(response.clientDataJSON),
+          attestationObject: toBase64url(response.attestationObject),
+          transports: response.getTransports?.() || [],
+        },
+      },
+    })
+    return data
+  },
+
+  rename: async (id: string, name: string): Promise<Passkey> => {
+    const response = await api.patch(`/me/passkeys/${id}`, { name })
+    return response.data
+  },
+
+  remove: async (id: string): Promise<void> => {
+    await api.delete(`/me/passkeys/${id}`)
+  },
+
+  login: async (username?: string): Promise<VerifyCaptchaResponse> => {
+    const { data: options } = await api.post('/auth/passkey/begin', { username })
+    const credential = (await navigator.credentials.get({
+      publicKey: {
+        ...options,
+        challenge: fromBase64url(options.challenge),
+        allowCredentials: toDescriptors(options.allowCredentials),
+      },
+    })) as PublicKeyCredential
+    const response = credential.response as AuthenticatorAssertionResponse
+
+    const { data } = await api.post('/auth/passkey/finish', {
+      credential: {
+        id: credential.id,
+        rawId: toBase64url(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64url(response.clientDataJSON),
+          authenticatorData: toBase64url(response.authenticatorData),
+          signature: toBase64url(response.signature),
+          userHandle: response.userHandle ? toBase64url(response.userHandle) : undefined,
+        },
+      },
+    })
+    return data
+  },
+}
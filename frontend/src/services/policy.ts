@@ -0,0 +1,51 @@
+import api from './api'
+
+export type PolicyAction =
+  | 'user.create'
+  | 'user.update'
+  | 'user.delete'
+  | 'role.create'
+  | 'role.update'
+  | 'role.delete'
+
+export interface PolicyRule {
+  name: string
+  description: string
+  actions: PolicyAction[]
+}
+
+export interface PolicyRuleResult {
+  rule: string
+  allowed: boolean
+  reason?: string
+}
+
+export interface PolicyDecision {
+  allowed: boolean
+  denied_by?: string
+  reason?: string
+  results: PolicyRuleResult[]
+}
+
+export interface EvaluatePolicyRequest {
+  action: PolicyAction
+  actor_id?: string
+  target_user_id?: string
+  target_role_id?: string
+  role_ids?: string[]
+  permission_ids?: string[]
+  rank?: number
+}
+
+export const policyService = {
+  getRules: async (): Promise<PolicyRule[]> => {
+    const response = await api.get('/policy/rules')
+    return response.data
+  },
+
+  // Dry run: reports what the policy engine would decide without changing anything
+  evaluate: async (data: EvaluatePolicyRequest): Promise<{ request: unknown; decision: PolicyDecision }> => {
+    const response = await api.post('/policy/evaluate', data)
+    return response.data
+  },
+}
@@ -4,6 +4,10 @@ export interface Role {
   id: string
   name: string
   description: string
+  built_in?: boolean
+  rank: number
+  parent_id?: string | null
+  parent?: Role
   created_at: string
 }
 
@@ -11,18 +15,49 @@ export interface Permission {
   id: string
   name: string
   description: string
+  orphaned?: boolean
   created_at: string
 }
 
+export interface SourcedPermission extends Permission {
+  source_role_id: string
+  source_role_name: string
+  inherited: boolean
+}
+
 export const rolesService = {
   getRoles: async (): Promise<Role[]> => {
     const response = await api.get('/roles')
     return response.data
   },
 
+  createRole: async (data: { name: string; description?: string; rank?: number; parent_id?: string; permission_ids?: string[] }): Promise<Role> => {
+    const response = await api.post('/roles', data)
+    return response.data
+  },
+
+  updateRole: async (roleId: string, data: { name?: string; description?: string; rank?: number }): Promise<Role> => {
+    const response = await api.put(`/roles/${roleId}`, data)
+    return response.data
+  },
+
+  // Users still holding the role must be moved to reassignTo
+  deleteRole: async (roleId: string, reassignTo?: string): Promise<{ message: string; users_moved: number }> => {
+    const response = await api.delete(`/roles/${roleId}`, {
+      params: reassignTo ? { reassign_to: reassignTo } : undefined,
+    })
+    return response.data
+  },
+
+  setParent: async (roleId: string, parentId: string | null): Promise<Role> => {
+    const response = await api.put(`/roles/${roleId}/parent`, { parent_id: parentId })
+    return response.data
+  },
+
   getRolePermissions: async (roleId: string): Promise<{
     role: Role
     permissions: Permission[]
+    effective_permissions: SourcedPermission[]
   }> => {
     const response = await api.get(`/roles/${roleId}/permissions`)
     return response.data
@@ -1,5 +1,6 @@
 import api from './api'
 import { User } from './auth'
+import { Permission, Role } from './roles'
 
 export interface CreateUserRequest {
   full_name: string
@@ -17,6 +18,18 @@ export interface UpdateUserRequest {
   is_active?: boolean
 }
 
+export interface PermissionOverride {
+  permission_id: string
+  effect: 'grant' | 'deny'
+}
+
+export interface UserAccess {
+  roles: (Role & { permissions?: Permission[] })[]
+  primary_role_id: string
+  overrides: (PermissionOverride & { id: string; permission: Permission })[]
+  effective_permissions: string[]
+}
+
 export const usersService = {
   getUsers: async (): Promise<User[]> => {
     const response = await api.get('/users')
@@ -41,4 +54,19 @@ export const usersService = {
   deleteUser: async (id: string): Promise<void> => {
     await api.delete(`/users/${id}`)
   },
+
+  getUserAccess: async (id: string): Promise<UserAccess> => {
+    const response = await api.get(`/users/${id}/permissions`)
+    return response.data
+  },
+
+  setUserRoles: async (id: string, roleIds: string[], primaryRoleId?: string): Promise<UserAccess> => {
+    const response = await api.put(`/users/${id}/roles`, { role_ids: roleIds, primary_role_id: primaryRoleId })
+    return response.data
+  },
+
+  setPermissionOverrides: async (id: string, overrides: PermissionOverride[]): Promise<UserAccess> => {
+    const response = await api.put(`/users/${id}/permission-overrides`, { overrides })
+    return response.data
+  },
 }
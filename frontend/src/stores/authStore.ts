@@ -44,6 +44,7 @@ export const useAuthStore = create<AuthState>((set, get) => {
       set({ user: null, token: null, permissions: [] })
       if (typeof window !== 'undefined') {
         localStorage.removeItem('token')
+        localStorage.removeItem('refresh_token')
         localStorage.removeItem('user')
         localStorage.removeItem('permissions')
       }